@@ -3,4 +3,24 @@ package config
 import "crypto/sha256"
 
 const HashSize = sha256.Size
-const MinerRewardBase = 100000000000
+
+//Limits of the script interpreter, every opcode costs 1 and signature checks cost ScriptSigCheckCost
+const (
+	MaxScriptSize        = 10000
+	MaxScriptElementSize = 520
+	MaxScriptStackSize   = 1000
+	MaxScriptCost        = 2000
+	ScriptSigCheckCost   = 50
+	MaxMultiSigKeys      = 16
+)
+
+//LockTimeThreshold lock times below are block heights, the others are timestamps in ms
+const LockTimeThreshold = 500000000
+
+//Relative lock times (the Sequence of an input) count blocks since the output being spent was confirmed,
+//or seconds if SequenceLockTimeIsTime is set
+const (
+	SequenceLockTimeIsTime        = 1 << 31
+	SequenceLockTimeMask          = SequenceLockTimeIsTime - 1
+	SequenceLockTimeGranularityMs = 1000
+)
@@ -0,0 +1,119 @@
+package config
+
+import "fmt"
+
+//Difficulty algorithms a chain can be configured with
+const (
+	DifficultyNone   = "none"   /* every hash reaches the difficulty */
+	DifficultySimple = "simple" /* adjusted by the interval of the last block */
+	DifficultyMA     = "ma"     /* moving average over the last samples */
+)
+
+//ChainParams defines the parameters of a network. Each blockchain keeps its own copy,
+//so differently configured chains can live in one process.
+type ChainParams struct {
+	Name string
+
+	TargetBlockIntervalMs uint64
+	DifficultyAlgorithm   string
+	InitialDifficulty     float64 /* probability for a hash to reach the initial difficulty */
+	DifficultyMASamples   uint32  /* only used by DifficultyMA */
+
+	MinerReward          uint64 /* coins created by each block for its miner, on top of the fees */
+	CoinbaseMaturity     uint64 /* blocks before a miner's reward can be spent */
+	MaxBlockTransactions int    /* max transactions in a block, excluding the reward */
+	MaxFutureBlockTimeMs uint64 /* how far a block may be ahead of the clock of the validating node */
+
+	PubKeyHashAddrID byte /* version byte of addresses paying to a public key on this network */
+	ScriptHashAddrID byte /* version byte of addresses paying to a locking script on this network */
+
+	MaxNullDataSize int /* max bytes carried by a null-data output */
+}
+
+//MainNetParams the parameters of the main network
+var MainNetParams = ChainParams{
+	Name:                  "mainnet",
+	TargetBlockIntervalMs: 10000,
+	DifficultyAlgorithm:   DifficultyMA,
+	InitialDifficulty:     0.2,
+	DifficultyMASamples:   16,
+	MinerReward:           100000000000,
+	CoinbaseMaturity:      100,
+	MaxBlockTransactions:  1000,
+	MaxFutureBlockTimeMs:  2 * 60 * 60 * 1000,
+	PubKeyHashAddrID:      0x00,
+	ScriptHashAddrID:      0x05,
+	MaxNullDataSize:       80,
+}
+
+//TestNetParams the parameters of the test network, used by the simulator
+var TestNetParams = ChainParams{
+	Name:                  "testnet",
+	TargetBlockIntervalMs: 10000,
+	DifficultyAlgorithm:   DifficultyMA,
+	InitialDifficulty:     0.2,
+	DifficultyMASamples:   16,
+	MinerReward:           100000000000,
+	CoinbaseMaturity:      5,
+	MaxBlockTransactions:  1000,
+	MaxFutureBlockTimeMs:  2 * 60 * 60 * 1000,
+	PubKeyHashAddrID:      0x6f,
+	ScriptHashAddrID:      0xc4,
+	MaxNullDataSize:       80,
+}
+
+//RegTestParams the parameters for regression tests, the difficulty is always met
+var RegTestParams = ChainParams{
+	Name:                  "regtest",
+	TargetBlockIntervalMs: 1,
+	DifficultyAlgorithm:   DifficultyNone,
+	InitialDifficulty:     1,
+	MinerReward:           100000000000,
+	CoinbaseMaturity:      0,
+	MaxBlockTransactions:  1000,
+	MaxFutureBlockTimeMs:  2 * 60 * 60 * 1000,
+	PubKeyHashAddrID:      0x6f,
+	ScriptHashAddrID:      0xc4,
+	MaxNullDataSize:       80,
+}
+
+//ParamsByName Get a copy of the named network parameters
+func ParamsByName(name string) (*ChainParams, error) {
+	for _, params := range []*ChainParams{&MainNetParams, &TestNetParams, &RegTestParams} {
+		if params.Name == name {
+			copied := *params
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown network %q", name)
+}
+
+//Validate Check the parameters are consistent
+func (params *ChainParams) Validate() error {
+	if params.TargetBlockIntervalMs == 0 {
+		return fmt.Errorf("Target block interval must be > 0")
+	}
+	if params.MaxBlockTransactions <= 0 {
+		return fmt.Errorf("Max block transactions must be > 0")
+	}
+	if params.PubKeyHashAddrID == params.ScriptHashAddrID {
+		return fmt.Errorf("Public key and script addresses must have different versions")
+	}
+	if params.MaxNullDataSize < 0 || params.MaxNullDataSize > MaxScriptElementSize {
+		return fmt.Errorf("Max null data size must be in [0, %d]", MaxScriptElementSize)
+	}
+
+	switch params.DifficultyAlgorithm {
+	case DifficultyNone:
+		return nil
+	case DifficultySimple, DifficultyMA:
+		if params.InitialDifficulty <= 0 || params.InitialDifficulty >= 1 {
+			return fmt.Errorf("Initial difficulty must be in (0, 1)")
+		}
+		if params.DifficultyAlgorithm == DifficultyMA && params.DifficultyMASamples == 0 {
+			return fmt.Errorf("MA difficulty needs samples > 0")
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown difficulty algorithm %q", params.DifficultyAlgorithm)
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"errors"
+
+	"../config"
+	"../util"
+)
+
+//HistoryEntry a credit (an output paid to the address) or a debit (an input spending one of its outputs)
+type HistoryEntry struct {
+	TxID          [config.HashSize]byte
+	Credit        bool /* false for a debit */
+	Value         uint64
+	Asset         AssetID
+	Height        uint64 /* index of the block confirming the transaction */
+	TimeStampMs   uint64 /* timestamp of the block */
+	Confirmations uint64 /* 1 for a transaction of the latest block */
+}
+
+/*
+ * The address index is shared by the copies of the chain, so it stays up to date whichever copy adds the blocks
+ */
+type addressIndex struct {
+	enabled bool
+	indexed int /* number of blocks indexed */
+	entries map[util.Address][]HistoryEntry
+}
+
+//EnableAddressIndex Record the credits and debits of every address from the genesis block on,
+//so the History of an address can be queried
+func (chain *Blockchain) EnableAddressIndex() {
+	chain.addressIndex.enabled = true
+	chain.indexBlocks()
+}
+
+//IsAddressIndexEnabled Check whether the History of addresses is recorded
+func (chain *Blockchain) IsAddressIndexEnabled() bool {
+	return chain.addressIndex.enabled
+}
+
+//History Get the credits and debits of an address in the order of the chain, skipping the first from ones
+//and returning at most limit of them (all the remaining ones if limit is 0). The address index must be enabled.
+func (chain *Blockchain) History(address util.Address, from int, limit int) ([]HistoryEntry, error) {
+	if !chain.addressIndex.enabled {
+		return nil, errors.New("Address index is not enabled")
+	}
+	if from < 0 || limit < 0 {
+		return nil, errors.New("Invalid history range")
+	}
+
+	entries := chain.addressIndex.entries[address]
+	if from >= len(entries) {
+		return nil, nil
+	}
+	entries = entries[from:]
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+
+	history := append([]HistoryEntry{}, entries...)
+	for i := range history {
+		history[i].Confirmations = chain.confirmationsAt(history[i].Height)
+	}
+	return history, nil
+}
+
+//BalanceWithConfirmations Check the balance of native coins of an Address confirmed by at least minConf blocks.
+//With minConf 0 the Transactions of the pool are counted as well: the coins they spend are left out,
+//and those they pay to the Address and don't spend are added.
+func (chain *Blockchain) BalanceWithConfirmations(Address util.Address, minConf uint64) uint64 {
+	var reserved map[UTXO]bool
+	if minConf == 0 {
+		reserved = chain.poolSpends()
+	}
+
+	var balance uint64
+	for utxo := range chain.AddressMap[Address] {
+		output := &chain.txMap[utxo.txMap].Outputs[utxo.outputIndex]
+		if output.Asset.IsNative() && !reserved[utxo] && chain.confirmationsAt(chain.blockIdxMap[utxo.txMap]) >= minConf {
+			balance += output.Value
+		}
+	}
+	if minConf > 0 {
+		return balance
+	}
+
+	for _, tran := range chain.TransactionPool {
+		if _, confirmed := chain.txMap[tran.TxID()]; confirmed {
+			continue
+		}
+		txID := tran.TxID()
+		for i, output := range tran.Outputs {
+			/* Chained pending Transactions may already spend the output */
+			if output.Address == Address && output.Asset.IsNative() && !reserved[UTXO{txMap: txID, outputIndex: uint32(i)}] {
+				balance += output.Value
+			}
+		}
+	}
+	return balance
+}
+
+/*
+ * Number of blocks confirming a transaction of the block at height
+ */
+func (chain *Blockchain) confirmationsAt(height uint64) uint64 {
+	return uint64(len(chain.blockList)) - height
+}
+
+/*
+ * Add the blocks not indexed yet to the address index
+ */
+func (chain *Blockchain) indexBlocks() {
+	index := chain.addressIndex
+	if !index.enabled {
+		return
+	}
+	for ; index.indexed < len(chain.blockList); index.indexed++ {
+		block := chain.blockList[index.indexed]
+		for i := range block.Transactions {
+			chain.indexTransaction(&block.Transactions[i], block)
+		}
+	}
+}
+
+func (chain *Blockchain) indexTransaction(tran *Transaction, block *Block) {
+	entries := chain.addressIndex.entries
+	entry := HistoryEntry{TxID: tran.TxID(), Height: block.blockIdx, TimeStampMs: block.timeStampMs}
+
+	for _, input := range tran.Inputs {
+		prev, exist := chain.txMap[input.PrevtxMap]
+		if !exist || input.OutputIndex >= uint32(len(prev.Outputs)) {
+			continue
+		}
+		output := &prev.Outputs[input.OutputIndex]
+		debit := entry
+		debit.Value = output.Value
+		debit.Asset = output.Asset
+		entries[output.Address] = append(entries[output.Address], debit)
+	}
+
+	for _, output := range tran.Outputs {
+		if output.IsNullData() {
+			continue
+		}
+		credit := entry
+		credit.Credit = true
+		credit.Value = output.Value
+		credit.Asset = output.Asset
+		entries[output.Address] = append(entries[output.Address], credit)
+	}
+}
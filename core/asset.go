@@ -0,0 +1,113 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"../config"
+	"../util"
+)
+
+//AssetID identifies the asset held by an output, the zero id is the native coin of the chain
+type AssetID [config.HashSize]byte
+
+//NativeAsset the coin mined by the miners and paying the fees
+var NativeAsset AssetID
+
+//NewAssetID Get the id of the asset named name issued by the owner of issuer.
+//Only the owner of issuer can issue more of it.
+func NewAssetID(issuer util.Address, name string) AssetID {
+	data := appendAddress(nil, issuer)
+	return sha256.Sum256(append(data, name...))
+}
+
+//IsNative Check whether the asset is the native coin
+func (id AssetID) IsNative() bool {
+	return id == NativeAsset
+}
+
+func (id AssetID) String() string {
+	if id.IsNative() {
+		return "native"
+	}
+	return hex.EncodeToString(id[:])
+}
+
+//AssetIssuance makes a transaction create Amount of the asset Name.
+//The issuer is the owner of the pay to public key hash output spent by the first input.
+type AssetIssuance struct {
+	Name   string /* empty if the transaction issues nothing */
+	Amount uint64
+}
+
+//Asset describes an asset issued on the chain
+type Asset struct {
+	Name   string
+	Issuer util.Address
+	Supply uint64
+}
+
+/*
+ * Get the id of the asset issued by a transaction, the first input spends prevOutput
+ */
+func (tran *Transaction) issuedAsset(prevOutput *TransactionOutput) (AssetID, error) {
+	if tran.Issuance.Amount == 0 {
+		return NativeAsset, errors.New("Issuance must create some of the asset")
+	}
+	if len(prevOutput.Script) != 0 || !prevOutput.Asset.IsNative() {
+		return NativeAsset, errors.New("Issuance must spend native coins of the issuer first")
+	}
+	return NewAssetID(prevOutput.Address, tran.Issuance.Name), nil
+}
+
+/*
+ * Get the supply of an asset once the issuances of parents, the Transactions earlier in the block, are performed.
+ * The parents were verified, so their issuances don't overflow the supply.
+ */
+func (chain *Blockchain) supplyAfter(asset AssetID, parents map[[config.HashSize]byte]*Transaction) uint64 {
+	supply := chain.assetMap[asset].Supply
+	for _, parent := range parents {
+		if parent.Issuance.Name == "" {
+			continue
+		}
+		input := parent.Inputs[0]
+		prev, exist := chain.txMap[input.PrevtxMap]
+		if !exist {
+			prev, exist = parents[input.PrevtxMap]
+		}
+		if exist && NewAssetID(prev.Outputs[input.OutputIndex].Address, parent.Issuance.Name) == asset {
+			supply += parent.Issuance.Amount
+		}
+	}
+	return supply
+}
+
+//AssetOf Get the description of an asset issued on the chain
+func (chain *Blockchain) AssetOf(id AssetID) (Asset, bool) {
+	asset, exist := chain.assetMap[id]
+	return asset, exist
+}
+
+//IssueAsset Make a transaction creating amount of the asset named name, paid to the issuer.
+//The fee is paid in native coins of the issuer. Note that the transaction is unsigned
+func (chain *Blockchain) IssueAsset(issuer util.Address, name string, amount uint64, fee uint64) (*Transaction, AssetID, error) {
+	if name == "" || amount == 0 {
+		return nil, NativeAsset, errors.New("Issuance needs a name and an amount > 0")
+	}
+	id := NewAssetID(issuer, name)
+	output := TransactionOutput{Value: amount, Address: issuer, Asset: id}
+	tx, _, err := chain.buildTransfer([]util.Address{issuer}, nil, output, fee, true, nil, nil)
+	if err != nil {
+		return nil, NativeAsset, err
+	}
+	tx.Issuance = AssetIssuance{Name: name, Amount: amount}
+	return tx, id, nil
+}
+
+//TransferAsset Make a transaction to transfer amount of an asset from one account to target Address.
+//The fee is paid in native coins. Note that the transaction is unsigned
+func (chain *Blockchain) TransferAsset(from util.Address, to util.Address, asset AssetID, amount uint64, fee uint64) (*Transaction, error) {
+	tx, _, err := chain.transfer(from, nil, TransactionOutput{Value: amount, Address: to, Asset: asset}, fee, nil)
+	return tx, err
+}
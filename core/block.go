@@ -2,7 +2,6 @@ package core
 
 import (
 	"bytes"
-	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
@@ -23,18 +22,18 @@ type Block struct {
 
 	blockValue   uint64 /* Mining Value of the block */
 	timeStampMs  uint64 /* Epoch when mined in ms */
-	minerAddress rsa.PublicKey
+	minerAddress util.Address
 	nuance       uint256 /* Use to mine so that hash Value must reach a specifc difficulty */
 
 	Transactions []Transaction
 }
 
-func createBlock(prevBlockHash [config.HashSize]byte, blockIdx uint64, timeStampMs uint64, minerAddress *rsa.PublicKey, transactions []Transaction) *Block {
+func createBlock(params *config.ChainParams, prevBlockHash [config.HashSize]byte, blockIdx uint64, timeStampMs uint64, minerAddress util.Address, transactions []Transaction) *Block {
 	var block Block
 	block.prevBlockHash = prevBlockHash
 	block.blockIdx = blockIdx
 	block.timeStampMs = timeStampMs
-	block.minerAddress = *minerAddress
+	block.minerAddress = minerAddress
 
 	/* Create a special transaction to reward miner (always as transaction 0) */
 	block.Transactions = append(block.Transactions, CreateTransaction(1, 1))
@@ -42,9 +41,9 @@ func createBlock(prevBlockHash [config.HashSize]byte, blockIdx uint64, timeStamp
 	binary.BigEndian.PutUint64(b, blockIdx)
 	copy(block.Transactions[0].Inputs[0].PrevtxMap[:], b)
 
-	/* TODO: 100 coins, should be adjusted based on timeStamp */
-	block.Transactions[0].Outputs[0].Value = config.MinerRewardBase
-	block.Transactions[0].Outputs[0].Address = *minerAddress
+	/* TODO: should be adjusted based on timeStamp */
+	block.Transactions[0].Outputs[0].Value = params.MinerReward
+	block.Transactions[0].Outputs[0].Address = minerAddress
 
 	/* Add real transactions */
 	block.AddTransactions(transactions)
@@ -53,22 +52,22 @@ func createBlock(prevBlockHash [config.HashSize]byte, blockIdx uint64, timeStamp
 	return &block
 }
 
-//CreateFirstBlock create first block of a chain.
-func CreateFirstBlock(timeStampMs uint64, minerAddress *rsa.PublicKey) *Block {
+//CreateFirstBlock create first block of a chain with the network parameters of the chain.
+func CreateFirstBlock(params *config.ChainParams, timeStampMs uint64, minerAddress util.Address) *Block {
 	var prevBlockHash [config.HashSize]byte /* doesn't matter for the first block*/
 	var trans []Transaction
-	return createBlock(prevBlockHash, 0, timeStampMs, minerAddress, trans)
+	return createBlock(params, prevBlockHash, 0, timeStampMs, minerAddress, trans)
 }
 
-//CreateNextEmptyBlock create next empty block of a chain.
-func CreateNextEmptyBlock(prevBlock *Block, timeStamp uint64, minerAddress *rsa.PublicKey) *Block {
+//CreateNextEmptyBlock create next empty block of a chain with the network parameters of the chain.
+func CreateNextEmptyBlock(params *config.ChainParams, prevBlock *Block, timeStamp uint64, minerAddress util.Address) *Block {
 	var trans []Transaction
-	return createBlock(prevBlock.hash, prevBlock.blockIdx+1, timeStamp, minerAddress, trans)
+	return createBlock(params, prevBlock.hash, prevBlock.blockIdx+1, timeStamp, minerAddress, trans)
 }
 
-//CreateNextBlock create next block of a chain.
-func CreateNextBlock(prevBlock *Block, timeStamp uint64, minerAddress *rsa.PublicKey, naunce uint64, transactions []Transaction) *Block {
-	block := createBlock(prevBlock.hash, prevBlock.blockIdx+1, timeStamp, minerAddress, transactions)
+//CreateNextBlock create next block of a chain with the network parameters of the chain.
+func CreateNextBlock(params *config.ChainParams, prevBlock *Block, timeStamp uint64, minerAddress util.Address, naunce uint64, transactions []Transaction) *Block {
+	block := createBlock(params, prevBlock.hash, prevBlock.blockIdx+1, timeStamp, minerAddress, transactions)
 
 	/* Finalize block */
 	block.nuance.data[0] = naunce
@@ -93,15 +92,36 @@ func (block *Block) getRawDataToHash() []byte {
 	 * Don't need to hash blockIdx, blockValue since they
 	 * can be derived from prevBlockHash and timeStamp
 	 */
-	data = appendAddress(data, &block.minerAddress)
+	data = appendAddress(data, block.minerAddress)
 	data = appendUint256(data, block.nuance)
 
-	for i := 0; i < len(block.Transactions); i++ {
-		data = append(data, block.Transactions[i].GetRawDataToHash()...)
-	}
+	txRoot := block.GetTxRoot()
+	witnessRoot := block.GetWitnessRoot()
+	data = append(data, txRoot[:]...)
+	data = append(data, witnessRoot[:]...)
 	return data
 }
 
+//GetTxRoot Get the commitment of the block to the ids of its Transactions
+func (block *Block) GetTxRoot() [config.HashSize]byte {
+	var data []byte
+	for i := range block.Transactions {
+		txID := block.Transactions[i].TxID()
+		data = append(data, txID[:]...)
+	}
+	return sha256.Sum256(data)
+}
+
+//GetWitnessRoot Get the commitment of the block to its Transactions including their unlocking scripts
+func (block *Block) GetWitnessRoot() [config.HashSize]byte {
+	var data []byte
+	for i := range block.Transactions {
+		witnessHash := block.Transactions[i].WitnessHash()
+		data = append(data, witnessHash[:]...)
+	}
+	return sha256.Sum256(data)
+}
+
 //FinalizeBlockAt Finalize a block with specified timestamp
 func (block *Block) FinalizeBlockAt(naunce uint64, timeStampMs uint64) {
 	block.nuance.data[0] = naunce
@@ -120,6 +140,16 @@ func (block *Block) GetBlockHash() [config.HashSize]byte {
 	return block.hash
 }
 
+//GetBlockIdx Get the height of the block in the chain
+func (block *Block) GetBlockIdx() uint64 {
+	return block.blockIdx
+}
+
+//GetTimeStampMs Get the epoch in ms when the block was mined
+func (block *Block) GetTimeStampMs() uint64 {
+	return block.timeStampMs
+}
+
 //Print details of block
 func (block *Block) Print() string {
 	var buffer bytes.Buffer
@@ -135,7 +165,7 @@ func (block *Block) Print() string {
 		block.blockIdx,
 		block.blockValue,
 		block.timeStampMs,
-		util.GetShortIdentity(block.minerAddress),
+		block.minerAddress.String(),
 		block.nuance,
 		buffer.String(),
 	)
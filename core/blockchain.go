@@ -2,10 +2,10 @@ package core
 
 import (
 	"bytes"
-	"crypto/rsa"
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"sort"
 
 	"../config"
 	"../util"
@@ -22,16 +22,39 @@ type UTXO struct {
 // - a set of unspent transaction output
 // - a set of Transactions indexed by tx hash
 type Blockchain struct {
-	txMap     map[[config.HashSize]byte]*Transaction /* map of all Transactions in the chain */
-	utxoMap   map[UTXO]bool                          /* map of all unspent transaction output (key is not used) */
-	blockMap  map[[config.HashSize]byte]*Block       /* map of all blocks */
-	blockList []*Block                               /* list of all blocks */
-
+	txMap       map[[config.HashSize]byte]*Transaction /* map of all Transactions in the chain */
+	utxoMap     map[UTXO]bool                          /* map of all unspent transaction output (key is not used) */
+	blockMap    map[[config.HashSize]byte]*Block       /* map of all blocks */
+	blockList   []*Block                               /* list of all blocks */
+	coinbaseMap map[[config.HashSize]byte]uint64       /* map of miner's reward Transactions to their block index */
+	blockIdxMap map[[config.HashSize]byte]uint64       /* map of all Transactions in the chain to their block index */
+	nullDataMap map[uint64][]NullData                  /* map of block index to the data anchored in the block */
+	assetMap    map[AssetID]Asset                      /* map of all issued assets */
+
+	params     *config.ChainParams
 	difficulty Difficulty
+	clock      util.Clock
+	chainID    string /* set when the chain is initialized from a genesis spec */
 
 	/* fields to support wallet */
-	AddressMap      map[rsa.PublicKey]map[UTXO]bool /* map of all Addresses to their utxo list */
-	TransactionPool map[string]*Transaction         /* all transaction broadcastd by user */
+	AddressMap      map[util.Address]map[UTXO]bool /* map of all Addresses to their utxo list */
+	TransactionPool map[string]*Transaction        /* all transaction broadcastd by user */
+	addressIndex    *addressIndex                  /* credits and debits of the Addresses, if enabled */
+}
+
+//GetChainID Get the chain id of the genesis spec, empty for ad hoc chains
+func (chain *Blockchain) GetChainID() string {
+	return chain.chainID
+}
+
+//Params Get the network parameters of the chain
+func (chain *Blockchain) Params() *config.ChainParams {
+	return chain.params
+}
+
+//Clock Get the clock the chain validates timestamps against
+func (chain *Blockchain) Clock() util.Clock {
+	return chain.clock
 }
 
 //GetDifficulty Get difficulty
@@ -39,11 +62,97 @@ func (chain *Blockchain) GetDifficulty() Difficulty {
 	return chain.difficulty
 }
 
-func (chain *Blockchain) verifyTransaction(tran *Transaction, inputMap map[UTXO]bool) (uint64, error) {
-	var totalInput uint64
-	var fromAddresses []*rsa.PublicKey
+/*
+ * Check whether a UTXO can be spent in the block at height.
+ * The miner's reward must wait for CoinbaseMaturity blocks, the genesis premine is spendable at once.
+ */
+func (chain *Blockchain) isMature(utxo UTXO, height uint64) bool {
+	minedAt, isCoinbase := chain.coinbaseMap[utxo.txMap]
+	return !isCoinbase || height >= minedAt+chain.params.CoinbaseMaturity
+}
 
-	for _, input := range tran.Inputs {
+/*
+ * Check whether the relative lock time of an input spending utxo allows it in the block at height with timeStampMs.
+ * A UTXO created in the same block is only spendable without relative lock time.
+ */
+func (chain *Blockchain) isSequenceFinal(sequence uint32, utxo UTXO, height uint64, timeStampMs uint64) bool {
+	lock := uint64(sequence & config.SequenceLockTimeMask)
+	confirmedAt, confirmed := chain.blockIdxMap[utxo.txMap]
+	if !confirmed {
+		return lock == 0
+	}
+	if sequence&config.SequenceLockTimeIsTime == 0 {
+		return height >= confirmedAt+lock
+	}
+	return timeStampMs >= chain.blockList[confirmedAt].timeStampMs+lock*config.SequenceLockTimeGranularityMs
+}
+
+/*
+ * Find the transaction creating an unspent output, either in the chain or among parents,
+ * the Transactions earlier in the same block. The UTXOs spent in the block are tracked separately.
+ */
+func (chain *Blockchain) findUnspent(utxo UTXO, parents map[[config.HashSize]byte]*Transaction) (*Transaction, bool) {
+	if _, unspent := chain.utxoMap[utxo]; unspent {
+		tx, exist := chain.txMap[utxo.txMap]
+		return tx, exist
+	}
+	tx, exist := parents[utxo.txMap]
+	if !exist || utxo.outputIndex >= uint32(len(tx.Outputs)) || tx.Outputs[utxo.outputIndex].IsNullData() {
+		return nil, false
+	}
+	return tx, true
+}
+
+/*
+ * Check an output can be added to the chain: its Address must belong to the network
+ * and commit to its locking script.
+ */
+func (chain *Blockchain) verifyOutput(output *TransactionOutput) error {
+	if data, ok := output.Script.NullData(); ok {
+		if output.Value != 0 {
+			return errors.New("Null data output can't carry value")
+		}
+		if len(data) > chain.params.MaxNullDataSize {
+			return fmt.Errorf("Null data output exceeds %d bytes", chain.params.MaxNullDataSize)
+		}
+		if !output.Address.IsZero() {
+			return errors.New("Null data output can't pay to an address")
+		}
+		return nil
+	}
+
+	if len(output.Script) == 0 {
+		if output.Address.Version() != chain.params.PubKeyHashAddrID {
+			return fmt.Errorf("Address %s doesn't belong to %s", output.Address, chain.params.Name)
+		}
+		return nil
+	}
+
+	if len(output.Script) > config.MaxScriptSize {
+		return fmt.Errorf("Locking script exceeds %d bytes", config.MaxScriptSize)
+	}
+	if output.Address != util.NewScriptAddress(chain.params.ScriptHashAddrID, output.Script) {
+		return fmt.Errorf("Address %s isn't the script address of %s on %s", output.Address, output.Script, chain.params.Name)
+	}
+	return nil
+}
+
+/*
+ * Verify a transaction to be included in the block at height with timeStampMs, after the Transactions of parents
+ * which spent the UTXOs of inputMap. Return the fee of the transaction.
+ */
+func (chain *Blockchain) verifyTransaction(tran *Transaction, inputMap map[UTXO]bool, parents map[[config.HashSize]byte]*Transaction, height uint64, timeStampMs uint64) (uint64, error) {
+	totalInput := make(map[AssetID]uint64)
+
+	if len(tran.Inputs) == 0 {
+		return 0, errors.New("Transaction must spend at least one UTXO")
+	}
+
+	if !tran.IsFinal(height, timeStampMs) {
+		return 0, fmt.Errorf("Transaction is locked until %d", tran.LockTime)
+	}
+
+	for i, input := range tran.Inputs {
 		var utxo UTXO
 		utxo.outputIndex = input.OutputIndex
 		utxo.txMap = input.PrevtxMap
@@ -58,63 +167,115 @@ func (chain *Blockchain) verifyTransaction(tran *Transaction, inputMap map[UTXO]
 		inputMap[utxo] = false
 
 		/*
-		 * Step 2: Verify if the UTXO exists in the chain
+		 * Step 2: Verify if the UTXO exists in the chain, or is created earlier in the block
 		 */
-		_, inUtxoMap := chain.utxoMap[utxo]
-		if !inUtxoMap {
+		tx, unspent := chain.findUnspent(utxo, parents)
+		if !unspent {
 			return 0, fmt.Errorf("Cannot find UTXO %s corresponding to an input in the chain %s, %s", util.Hash(utxo), chain.PrintUTXOMap(), tran.Print())
 		}
 
 		/*
 		 * Step 3: Sanity check if the UTXO has a valid transaction
 		 */
-		tx, intxMap := chain.txMap[utxo.txMap]
-		if !intxMap {
-			return 0, fmt.Errorf("Blockchain is corrupted: cannot find tx %s", tx.Print())
+		if tx == nil {
+			return 0, fmt.Errorf("Blockchain is corrupted: cannot find tx of UTXO %s", util.Hash(utxo))
 		}
 		if utxo.outputIndex >= uint32(len(tx.Outputs)) {
 			return 0, errors.New("Blockchain is corrupted: cannot find utxo")
 		}
+		if !chain.isMature(utxo, height) {
+			return 0, fmt.Errorf("Cannot spend miner's reward %s before it matures", util.Hash(utxo))
+		}
+		if !chain.isSequenceFinal(input.Sequence, utxo, height, timeStampMs) {
+			return 0, fmt.Errorf("Cannot spend UTXO %s before its relative lock time %x", util.Hash(utxo), input.Sequence)
+		}
 
-		totalInput += tx.Outputs[utxo.outputIndex].Value
-		fromAddresses = append(fromAddresses, &tx.Outputs[utxo.outputIndex].Address)
-	}
+		/*
+		 * Step 4: Verify the unlocking script satisfies the locking script of the UTXO
+		 */
+		ctx := scriptContext{tran: tran, inputIndex: i, spent: &tx.Outputs[utxo.outputIndex]}
+		err := executeScripts(input.Script, tx.Outputs[utxo.outputIndex].LockingScript(), &ctx)
+		if err != nil {
+			return 0, fmt.Errorf("Cannot spend UTXO %s: %s", util.Hash(utxo), err)
+		}
 
-	/*
-	 * Step 4: Verify signatures
-	 */
-	err := tran.VerifyTransaction(fromAddresses)
-	if err != nil {
-		return 0, err
+		prevOutput := &tx.Outputs[utxo.outputIndex]
+		if err := addToTotal(totalInput, prevOutput.Asset, prevOutput.Value); err != nil {
+			return 0, err
+		}
+
+		/*
+		 * Step 5: The issuer of an asset is the owner of the first input
+		 */
+		if i == 0 && tran.Issuance.Name != "" {
+			asset, err := tran.issuedAsset(prevOutput)
+			if err != nil {
+				return 0, err
+			}
+			supply := chain.supplyAfter(asset, parents)
+			if supply+tran.Issuance.Amount < supply {
+				return 0, fmt.Errorf("Issuance overflows the supply of asset %s", asset)
+			}
+			if err := addToTotal(totalInput, asset, tran.Issuance.Amount); err != nil {
+				return 0, err
+			}
+		}
 	}
 
 	/*
-	 * Step 5: Make sure total input <= total output (the gap is the transaction fee)
+	 * Step 6: Make sure total input <= total output for the native coin (the gap is the transaction fee),
+	 * every other asset is conserved
 	 */
-	var totalOutput uint64
+	totalOutput := make(map[AssetID]uint64)
 	for _, output := range tran.Outputs {
-		totalOutput += output.Value
+		if err := chain.verifyOutput(&output); err != nil {
+			return 0, err
+		}
+		if err := addToTotal(totalOutput, output.Asset, output.Value); err != nil {
+			return 0, err
+		}
 	}
-	if totalOutput > totalInput {
+	if totalOutput[NativeAsset] > totalInput[NativeAsset] {
 		return 0, errors.New("The Value of total Outputs exceed that of Inputs")
 	}
+	for asset := range totalInput {
+		if !asset.IsNative() && totalOutput[asset] != totalInput[asset] {
+			return 0, fmt.Errorf("Asset %s is not conserved", asset)
+		}
+	}
+	for asset := range totalOutput {
+		if !asset.IsNative() && totalOutput[asset] != totalInput[asset] {
+			return 0, fmt.Errorf("Asset %s is not conserved", asset)
+		}
+	}
 
-	return totalInput - totalOutput, nil
+	return totalInput[NativeAsset] - totalOutput[NativeAsset], nil
 
 }
 
-func (chain *Blockchain) addUTXOToAddress(utxo *UTXO, Address *rsa.PublicKey) {
-	m, exist := chain.AddressMap[*Address]
+/*
+ * Add value to the total of an asset, failing instead of wrapping around
+ */
+func addToTotal(totals map[AssetID]uint64, asset AssetID, value uint64) error {
+	if totals[asset]+value < totals[asset] {
+		return fmt.Errorf("Total value of asset %s overflows", asset)
+	}
+	totals[asset] += value
+	return nil
+}
+
+func (chain *Blockchain) addUTXOToAddress(utxo *UTXO, Address util.Address) {
+	m, exist := chain.AddressMap[Address]
 	if !exist {
 		m = make(map[UTXO]bool)
-		chain.AddressMap[*Address] = m
+		chain.AddressMap[Address] = m
 	}
 
 	m[*utxo] = false
 }
 
-func (chain *Blockchain) removeUTXOFromAddress(utxo *UTXO, Address *rsa.PublicKey) {
-	m, exist := chain.AddressMap[*Address]
+func (chain *Blockchain) removeUTXOFromAddress(utxo *UTXO, Address util.Address) {
+	m, exist := chain.AddressMap[Address]
 	if !exist {
 		return
 	}
@@ -125,9 +286,19 @@ func (chain *Blockchain) removeUTXOFromAddress(utxo *UTXO, Address *rsa.PublicKe
 /*
  * Perform the transaction atomically assuming the transaction is valid.
  */
-func (chain *Blockchain) performTransaction(tran *Transaction) {
-	txMap := sha256.Sum256(tran.GetRawDataToHash())
+func (chain *Blockchain) performTransaction(tran *Transaction, blockIdx uint64) {
+	txMap := tran.TxID()
 	chain.txMap[txMap] = tran
+	chain.blockIdxMap[txMap] = blockIdx
+	if tran.Issuance.Name != "" {
+		issuer := chain.txMap[tran.Inputs[0].PrevtxMap].Outputs[tran.Inputs[0].OutputIndex].Address
+		asset := NewAssetID(issuer, tran.Issuance.Name)
+		chain.assetMap[asset] = Asset{
+			Name:   tran.Issuance.Name,
+			Issuer: issuer,
+			Supply: chain.assetMap[asset].Supply + tran.Issuance.Amount,
+		}
+	}
 	for _, input := range tran.Inputs {
 		var utxo UTXO
 		utxo.outputIndex = input.OutputIndex
@@ -135,14 +306,20 @@ func (chain *Blockchain) performTransaction(tran *Transaction) {
 
 		delete(chain.utxoMap, utxo)
 		tx := chain.txMap[input.PrevtxMap]
-		chain.removeUTXOFromAddress(&utxo, &tx.Outputs[utxo.outputIndex].Address)
+		chain.removeUTXOFromAddress(&utxo, tx.Outputs[utxo.outputIndex].Address)
 	}
 	for i, output := range tran.Outputs {
+		/* Null data can't be spent, only index it */
+		if data, ok := output.Script.NullData(); ok {
+			chain.nullDataMap[blockIdx] = append(chain.nullDataMap[blockIdx], NullData{TxID: txMap, OutputIndex: uint32(i), Data: data})
+			continue
+		}
+
 		var utxo UTXO
 		utxo.outputIndex = uint32(i)
 		utxo.txMap = txMap
 		chain.utxoMap[utxo] = false
-		chain.addUTXOToAddress(&utxo, &output.Address)
+		chain.addUTXOToAddress(&utxo, output.Address)
 	}
 
 	// remove the transaction from the poposal pool
@@ -151,16 +328,25 @@ func (chain *Blockchain) performTransaction(tran *Transaction) {
 }
 
 func (chain *Blockchain) performMinerTransactionAndAddBlock(block *Block) {
-	var utxo UTXO
-	utxo.outputIndex = 0
-	utxo.txMap = sha256.Sum256(block.Transactions[0].GetRawDataToHash())
-	chain.utxoMap[utxo] = false
-	chain.txMap[utxo.txMap] = &block.Transactions[0]
-	chain.addUTXOToAddress(&utxo, &block.minerAddress)
+	/* Only the genesis block pays more than one output (the premine) */
+	txMap := block.Transactions[0].TxID()
+	chain.txMap[txMap] = &block.Transactions[0]
+	chain.blockIdxMap[txMap] = block.blockIdx
+	if block.blockIdx > 0 {
+		chain.coinbaseMap[txMap] = block.blockIdx
+	}
+	for i, output := range block.Transactions[0].Outputs {
+		var utxo UTXO
+		utxo.outputIndex = uint32(i)
+		utxo.txMap = txMap
+		chain.utxoMap[utxo] = false
+		chain.addUTXOToAddress(&utxo, output.Address)
+	}
 
 	chain.blockList = append(chain.blockList, block)
 	blockHash := sha256.Sum256(block.getRawDataToHash())
 	chain.blockMap[blockHash] = block
+	chain.indexBlocks()
 }
 
 //AddBlock Append the block to the end of the chain.
@@ -182,26 +368,41 @@ func (chain *Blockchain) AddBlock(block *Block) error {
 		return errors.New("Only one miner is allowed in each block")
 	}
 
+	if !block.Transactions[0].Outputs[0].Asset.IsNative() {
+		return errors.New("Miner's reward must be paid in native coins")
+	}
+
+	if err := chain.verifyOutput(&block.Transactions[0].Outputs[0]); err != nil {
+		return err
+	}
+
+	if len(block.Transactions)-1 > chain.params.MaxBlockTransactions {
+		return fmt.Errorf("A block can contain at most %d Transactions", chain.params.MaxBlockTransactions)
+	}
+
 	var inputMap map[UTXO]bool
 	inputMap = make(map[UTXO]bool)
+	parents := make(map[[config.HashSize]byte]*Transaction)
 	var totalFee uint64
-	for i, tx := range block.Transactions {
+	for i := range block.Transactions {
 		/* Ignore the first transaction which contains miner's reward */
 		if i == 0 {
 			continue
 		}
 
+		tx := &block.Transactions[i]
 		util.GetBlockchainLogger().Debugf("Start to confirm transaction: %s\n", tx.Print())
-		fee, error := chain.verifyTransaction(&tx, inputMap)
+		fee, error := chain.verifyTransaction(tx, inputMap, parents, block.blockIdx, block.timeStampMs)
 		if error != nil {
 			return error
 		}
+		parents[tx.TxID()] = tx
 		totalFee += fee
 	}
 
 	/* 100 coins as base award, should be adjusted based on time */
 	var minerReward uint64
-	minerReward = config.MinerRewardBase + totalFee
+	minerReward = chain.params.MinerReward + totalFee
 	if block.Transactions[0].Outputs[0].Value > minerReward {
 		return errors.New("Miner's reward exceeds base + fee")
 	}
@@ -210,6 +411,10 @@ func (chain *Blockchain) AddBlock(block *Block) error {
 		return errors.New("Timestamp must be monotonic increasing")
 	}
 
+	if block.timeStampMs > chain.clock.NowMs()+chain.params.MaxFutureBlockTimeMs {
+		return errors.New("Timestamp is too far in the future")
+	}
+
 	if !chain.ReachDifficulty(block) {
 		return errors.New("The block doesn't meet difficulty")
 	}
@@ -221,7 +426,7 @@ func (chain *Blockchain) AddBlock(block *Block) error {
 		if i == 0 {
 			continue
 		}
-		chain.performTransaction(&block.Transactions[i])
+		chain.performTransaction(&block.Transactions[i], block.blockIdx)
 	}
 
 	chain.difficulty.UpdateDifficulty(block.timeStampMs - chain.GetLatestBlock().timeStampMs)
@@ -237,6 +442,14 @@ func (chain *Blockchain) GetNLatestBlock(n int) *Block {
 	return chain.blockList[len(chain.blockList)-n]
 }
 
+//GetBlockAt Get the block at a height, nil if the chain is not that high
+func (chain *Blockchain) GetBlockAt(blockIdx uint64) *Block {
+	if blockIdx >= uint64(len(chain.blockList)) {
+		return nil
+	}
+	return chain.blockList[blockIdx]
+}
+
 //GetLatestBlock Get the latest block
 func (chain *Blockchain) GetLatestBlock() *Block {
 	return chain.GetNLatestBlock(1)
@@ -248,31 +461,116 @@ func (chain *Blockchain) ReachDifficulty(block *Block) bool {
 }
 
 //RegisterUser Register user
-func (chain *Blockchain) RegisterUser(user rsa.PublicKey, utxoMap map[UTXO]bool) {
+func (chain *Blockchain) RegisterUser(user util.Address, utxoMap map[UTXO]bool) {
 	chain.AddressMap[user] = utxoMap
 }
 
 //AcceptBroadcastedTransaction Accept transaction which broadchated by others.
+//Transactions which are not final yet (see LockTime and Sequence) stay in the pool until they are.
 func (chain *Blockchain) AcceptBroadcastedTransaction(tran *Transaction) {
 	chain.TransactionPool[util.Hash(tran)] = tran
 }
 
+//SelectPoolTransactions Pick the Transactions in the pool that are valid in the next block with timeStampMs,
+//in a deterministic order. A transaction spending the outputs of another one of the pool follows it in the block.
+//Invalid and non-final Transactions stay in the pool.
+//Return the Transactions and their total fee.
+func (chain *Blockchain) SelectPoolTransactions(timeStampMs uint64) ([]Transaction, uint64) {
+	var keys []string
+	for key := range chain.TransactionPool {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	height := uint64(len(chain.blockList))
+	var selected []Transaction
+	var totalFee uint64
+	inputMap := make(map[UTXO]bool)
+	parents := make(map[[config.HashSize]byte]*Transaction)
+	/* Go over the pool again as long as it adds Transactions, their children may be valid now */
+	for added := true; added; {
+		added = false
+		var remaining []string
+		for _, key := range keys {
+			if len(selected) >= chain.params.MaxBlockTransactions {
+				break
+			}
+
+			/* Verify against a copy so a rejected transaction doesn't reserve its Inputs */
+			tryMap := make(map[UTXO]bool)
+			for utxo := range inputMap {
+				tryMap[utxo] = false
+			}
+
+			tran := chain.TransactionPool[key]
+			fee, err := chain.verifyTransaction(tran, tryMap, parents, height, timeStampMs)
+			if err != nil {
+				util.GetBlockchainLogger().Debugf("Skip transaction %s: %s\n", key, err)
+				remaining = append(remaining, key)
+				continue
+			}
+			inputMap = tryMap
+			parents[tran.TxID()] = tran
+			selected = append(selected, *tran)
+			totalFee += fee
+			added = true
+		}
+		keys = remaining
+	}
+	return selected, totalFee
+}
+
 /***********************************
  * Wallet related methods
  **********************************/
 
-// BalanceOf Check the balance of an Address
-func (chain *Blockchain) BalanceOf(Address *rsa.PublicKey) uint64 {
-	m, exist := chain.AddressMap[*Address]
+// OutputOf Get the output in the chain or in the pool an input spends, e.g. to find the key signing the input
+func (chain *Blockchain) OutputOf(input *TransactionInput) (*TransactionOutput, bool) {
+	tx, exist := chain.txMap[input.PrevtxMap]
+	if !exist {
+		tx, exist = chain.poolTransaction(input.PrevtxMap)
+	}
+	if !exist || input.OutputIndex >= uint32(len(tx.Outputs)) {
+		return nil, false
+	}
+	return &tx.Outputs[input.OutputIndex], true
+}
+
+// BalanceOf Check the balance of native coins of an Address
+func (chain *Blockchain) BalanceOf(Address util.Address) uint64 {
+	return chain.BalanceOfAsset(Address, NativeAsset)
+}
+
+// BalanceOfAsset Check the balance of an asset of an Address
+func (chain *Blockchain) BalanceOfAsset(Address util.Address, asset AssetID) uint64 {
+	m, exist := chain.AddressMap[Address]
 	if !exist {
-		util.GetBlockchainLogger().Errorf("Address %x disappear from chain\n", *Address)
+		util.GetBlockchainLogger().Errorf("Address %s disappear from chain\n", Address.String())
 		return 0
 	}
 
 	var balance uint64
 	for utxo := range m {
-		tx := chain.txMap[utxo.txMap]
-		balance += tx.Outputs[utxo.outputIndex].Value
+		output := &chain.txMap[utxo.txMap].Outputs[utxo.outputIndex]
+		if output.Asset == asset {
+			balance += output.Value
+		}
+	}
+	return balance
+}
+
+// SpendableBalanceOf Check the balance of native coins of an Address that can be spent in the next block:
+// the coins spent by the pool are left out, the change of the pending Transactions of the Address is counted.
+func (chain *Blockchain) SpendableBalanceOf(Address util.Address) uint64 {
+	return chain.SpendableBalanceFrom([]util.Address{Address})
+}
+
+// SpendableBalanceFrom Check the balance of native coins of several Addresses that can be spent in the next block,
+// e.g. all the Addresses of a wallet, counting the change the Addresses sent to each other in the pool.
+func (chain *Blockchain) SpendableBalanceFrom(froms []util.Address) uint64 {
+	var balance uint64
+	for _, coin := range chain.spendableCoins(froms, NativeAsset) {
+		balance += coin.Value
 	}
 	return balance
 }
@@ -280,53 +578,247 @@ func (chain *Blockchain) BalanceOf(Address *rsa.PublicKey) uint64 {
 // TransferCoin Make a transaction to transfer coins from one account to target Address.
 // Return nil if there is insufficient fund or amount is zero
 // Note that the transaction is unsigned
-func (chain *Blockchain) TransferCoin(from *rsa.PublicKey, to *rsa.PublicKey, amount uint64, fee uint64) (*Transaction, error) {
+func (chain *Blockchain) TransferCoin(from util.Address, to util.Address, amount uint64, fee uint64) (*Transaction, error) {
+	tx, _, err := chain.TransferCoinWith(from, to, amount, fee, nil)
+	return tx, err
+}
+
+// TransferCoinWith Make a transaction to transfer coins, selecting the coins spent with selector,
+// BranchAndBound if it is nil. Return the selection of the coins as well.
+// Note that the transaction is unsigned
+func (chain *Blockchain) TransferCoinWith(from util.Address, to util.Address, amount uint64, fee uint64, selector CoinSelector) (*Transaction, *CoinSelection, error) {
+	var output TransactionOutput
+	output.Address = to
+	output.Value = amount
+	return chain.transfer(from, nil, output, fee, selector)
+}
+
+//ChangeAddress Get the Address receiving the change of a transaction, it's called only if there is change
+type ChangeAddress func() (util.Address, error)
+
+// TransferCoinFrom Make a transaction paying amount to an Address from the native coins of several Addresses,
+// e.g. all the Addresses of a wallet, selected with selector (BranchAndBound if nil). The change goes to the Address
+// given by change, so a wallet can use a new one for every payment instead of reusing its Addresses.
+// Note that the transaction is unsigned
+func (chain *Blockchain) TransferCoinFrom(froms []util.Address, to util.Address, amount uint64, fee uint64, change ChangeAddress, selector CoinSelector) (*Transaction, *CoinSelection, error) {
 	if amount == 0 {
-		return nil, fmt.Errorf("amount needs > 0")
+		return nil, nil, fmt.Errorf("amount needs > 0")
+	}
+	if len(froms) == 0 {
+		return nil, nil, fmt.Errorf("no address to spend from")
+	}
+	return chain.buildTransfer(froms, nil, TransactionOutput{Value: amount, Address: to}, fee, false, change, selector)
+}
+
+// TransferToScript Make a transaction to lock coins of an account with a script, e.g. a multisig script.
+// Note that the transaction is unsigned
+func (chain *Blockchain) TransferToScript(from util.Address, script Script, amount uint64, fee uint64) (*Transaction, error) {
+	var output TransactionOutput
+	output.LockWithScript(script, chain.params)
+	output.Value = amount
+	tx, _, err := chain.transfer(from, nil, output, fee, nil)
+	return tx, err
+}
+
+// TransferFromScript Make a transaction to spend coins locked by a script, the change is locked by the same script.
+// Note that the unlocking scripts are empty, e.g. each signer of a multisig script adds their signature
+// with SignMultiSigInput
+func (chain *Blockchain) TransferFromScript(script Script, to util.Address, amount uint64, fee uint64) (*Transaction, error) {
+	var output TransactionOutput
+	output.Address = to
+	output.Value = amount
+	tx, _, err := chain.transfer(chain.ScriptAddress(script), script, output, fee, nil)
+	return tx, err
+}
+
+// ScriptAddress Get the address of the outputs locked by a script on this chain
+func (chain *Blockchain) ScriptAddress(script Script) util.Address {
+	return util.NewScriptAddress(chain.params.ScriptHashAddrID, script)
+}
+
+/*
+ * Build a transaction paying output from the coins of an address, fromScript is the locking script
+ * of these coins if they aren't paid to a public key hash. The change goes back to the same address.
+ */
+func (chain *Blockchain) transfer(from util.Address, fromScript Script, output TransactionOutput, fee uint64, selector CoinSelector) (*Transaction, *CoinSelection, error) {
+	amount := output.Value
+	if amount == 0 && !output.IsNullData() {
+		return nil, nil, fmt.Errorf("amount needs > 0")
 	}
 
-	if chain.BalanceOf(from) < amount {
-		return nil, fmt.Errorf("user %s has no enough balance", util.GetShortIdentity(*from))
+	if output.Asset.IsNative() && chain.BalanceOf(from) < amount+fee {
+		return nil, nil, fmt.Errorf("user %s has no enough balance", from.String())
+	}
+	if chain.BalanceOfAsset(from, output.Asset) < amount {
+		return nil, nil, fmt.Errorf("user %s has no enough balance", from.String())
 	}
 
-	fromMap := chain.AddressMap[*from]
-	var utxoList []UTXO
-	var fromAmount uint64
-	for fromUTXO := range fromMap {
-		utxoList = append(utxoList, fromUTXO)
-		fromTx := chain.txMap[fromUTXO.txMap]
-		fromAmount += fromTx.Outputs[fromUTXO.outputIndex].Value
+	return chain.buildTransfer([]util.Address{from}, fromScript, output, fee, false, nil, selector)
+}
 
-		if fromAmount >= amount+fee {
-			break
+/*
+ * Get the outputs of a set of addresses holding asset which a transaction can spend in the next block:
+ * the mature UTXOs not spent by the pool yet, and the outputs paid to the addresses by the Transactions they sent
+ * to the pool, so a sender never spends the same coins twice and doesn't wait for the confirmation of its change.
+ * The pending payments of others, and the Transactions that can't be mined in the next block, aren't counted.
+ */
+func (chain *Blockchain) spendableCoins(froms []util.Address, asset AssetID) []Coin {
+	owned := make(map[util.Address]bool)
+	for _, from := range froms {
+		owned[from] = true
+	}
+	reserved := chain.poolSpends()
+	height := uint64(len(chain.blockList))
+	now := chain.clock.NowMs()
+	var coins []Coin
+	for _, from := range froms {
+		for utxo := range chain.AddressMap[from] {
+			output := &chain.txMap[utxo.txMap].Outputs[utxo.outputIndex]
+			if output.Asset == asset && chain.isMature(utxo, height) && !reserved[utxo] {
+				coins = append(coins, Coin{UTXO: utxo, Value: output.Value})
+			}
 		}
 	}
 
-	var outputLen int
-	if amount+fee == fromAmount {
-		outputLen = 1
-	} else {
-		outputLen = 2
+	for _, tran := range chain.TransactionPool {
+		if !owned[tran.Sender] || !chain.isFinal(tran, height, now) {
+			continue
+		}
+		txID := tran.TxID()
+		if _, confirmed := chain.txMap[txID]; confirmed {
+			continue
+		}
+		for i, output := range tran.Outputs {
+			utxo := UTXO{txMap: txID, outputIndex: uint32(i)}
+			if owned[output.Address] && output.Asset == asset && !output.IsNullData() && !reserved[utxo] {
+				coins = append(coins, Coin{UTXO: utxo, Value: output.Value})
+			}
+		}
+	}
+	return coins
+}
+
+/*
+ * Name the owner of a set of addresses in errors, the address itself if there is only one
+ */
+func describeAddresses(froms []util.Address) string {
+	if len(froms) == 1 {
+		return froms[0].String()
 	}
+	return fmt.Sprintf("%d addresses", len(froms))
+}
 
-	tx := CreateTransaction(len(utxoList), outputLen)
-	for i, utxo := range utxoList {
-		tx.Inputs[i].OutputIndex = utxo.outputIndex
-		tx.Inputs[i].PrevtxMap = utxo.txMap
+/*
+ * Get the UTXOs spent by the Transactions of the pool
+ */
+func (chain *Blockchain) poolSpends() map[UTXO]bool {
+	spent := make(map[UTXO]bool)
+	for _, tran := range chain.TransactionPool {
+		for _, input := range tran.Inputs {
+			spent[UTXO{txMap: input.PrevtxMap, outputIndex: input.OutputIndex}] = true
+		}
 	}
+	return spent
+}
 
-	tx.Outputs[0].Address = *to
-	tx.Outputs[0].Value = amount
+/*
+ * Check whether both the lock time and the relative lock times of a transaction allow it
+ * in the block at height with timeStampMs
+ */
+func (chain *Blockchain) isFinal(tran *Transaction, height uint64, timeStampMs uint64) bool {
+	if !tran.IsFinal(height, timeStampMs) {
+		return false
+	}
+	for _, input := range tran.Inputs {
+		utxo := UTXO{txMap: input.PrevtxMap, outputIndex: input.OutputIndex}
+		if !chain.isSequenceFinal(input.Sequence, utxo, height, timeStampMs) {
+			return false
+		}
+	}
+	return true
+}
 
-	if outputLen == 2 {
-		tx.Outputs[1].Address = *from
-		tx.Outputs[1].Value = fromAmount - amount - fee
+/*
+ * Find a transaction of the pool by its id
+ */
+func (chain *Blockchain) poolTransaction(txID [config.HashSize]byte) (*Transaction, bool) {
+	for _, tran := range chain.TransactionPool {
+		if tran.TxID() == txID {
+			return tran, true
+		}
+	}
+	return nil, false
+}
+
+/*
+ * Build a transaction paying output from the coins of froms, the fee is paid in native coins selected by selector.
+ * The native coins are spent first, so the first input of an issuance belongs to the issuer. An issuance doesn't
+ * spend the asset it pays. The change goes to the Address given by change, back to the first of froms if nil.
+ * Return the selection of the native coins, nil if no native coin is spent.
+ */
+func (chain *Blockchain) buildTransfer(froms []util.Address, fromScript Script, output TransactionOutput, fee uint64, issuance bool, change ChangeAddress, selector CoinSelector) (*Transaction, *CoinSelection, error) {
+	if selector == nil {
+		selector = BranchAndBound{}
+	}
+	owner := describeAddresses(froms)
+
+	nativeTarget := CoinTarget{Fee: fee}
+	var assetSelection *CoinSelection
+	if output.Asset.IsNative() {
+		nativeTarget.Amount = output.Value
+	} else if !issuance {
+		var err error
+		assetSelection, err = selector.SelectCoins(chain.spendableCoins(froms, output.Asset), CoinTarget{Amount: output.Value})
+		if err != nil {
+			return nil, nil, fmt.Errorf("user %s has no enough spendable %s: %s", owner, output.Asset, err)
+		}
+	}
+
+	var nativeSelection *CoinSelection
+	if fee > 0 || assetSelection == nil {
+		var err error
+		nativeSelection, err = selector.SelectCoins(chain.spendableCoins(froms, NativeAsset), nativeTarget)
+		if err != nil {
+			return nil, nil, fmt.Errorf("user %s has no enough spendable balance: %s", owner, err)
+		}
+	}
+
+	var coins []Coin
+	if nativeSelection != nil {
+		coins = append(coins, nativeSelection.Coins...)
+	}
+	if assetSelection != nil {
+		coins = append(coins, assetSelection.Coins...)
+	}
+	tx := CreateTransaction(len(coins), 1)
+	for i, coin := range coins {
+		tx.Inputs[i].OutputIndex = coin.UTXO.outputIndex
+		tx.Inputs[i].PrevtxMap = coin.UTXO.txMap
+	}
+
+	tx.Outputs[0] = output
+
+	/* The change address is only asked for once the coins are selected, so no address is spent on a payment without change */
+	hasChange := (nativeSelection != nil && nativeSelection.Change > 0) || (assetSelection != nil && assetSelection.Change > 0)
+	changeTo := froms[0]
+	if hasChange && change != nil {
+		var err error
+		if changeTo, err = change(); err != nil {
+			return nil, nil, err
+		}
+		fromScript = nil
+	}
+	if nativeSelection != nil && nativeSelection.Change > 0 {
+		tx.Outputs = append(tx.Outputs, TransactionOutput{Value: nativeSelection.Change, Address: changeTo, Script: fromScript})
+	}
+	if assetSelection != nil && assetSelection.Change > 0 {
+		tx.Outputs = append(tx.Outputs, TransactionOutput{Value: assetSelection.Change, Address: changeTo, Script: fromScript, Asset: output.Asset})
 	}
 
-	tx.Sender = *from
+	tx.Sender = froms[0]
 
 	//util.GetBlockchainLogger().Debugf("Constructed transaction %v", tx)
-	return &tx, nil
+	return &tx, nativeSelection, nil
 }
 
 //PrintTransactionPool Print details information of transactions in a chain.
@@ -363,7 +855,7 @@ func (chain *Blockchain) printUTXOMap(utxoMap map[UTXO]bool) string {
 func (chain *Blockchain) PrintAddressMap() string {
 	var buffer bytes.Buffer
 	for address, utxos := range chain.AddressMap {
-		buffer.WriteString(fmt.Sprintf("%s:%s", util.GetShortIdentity(address), chain.printUTXOMap(utxos)))
+		buffer.WriteString(fmt.Sprintf("%s:%s", address.String(), chain.printUTXOMap(utxos)))
 	}
 
 	return fmt.Sprintf("AddressMap:[%s],", buffer.String())
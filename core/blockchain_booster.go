@@ -1,40 +1,74 @@
 package core
 
 import (
-	"crypto/rsa"
-	"time"
-
 	"../config"
+	"../util"
 )
 
-//InitializeBlockchainWithDiff creates a blockchain from scratch
-func InitializeBlockchainWithDiff(gensisAddress *rsa.PublicKey, diff Difficulty) Blockchain {
+func createEmptyBlockchain(params *config.ChainParams, diff Difficulty, clock util.Clock) Blockchain {
 	var chain Blockchain
+	copied := *params /* the chain never shares its parameters */
+	chain.params = &copied
 	chain.txMap = make(map[[config.HashSize]byte]*Transaction)
 	chain.utxoMap = make(map[UTXO]bool)
 	chain.blockMap = make(map[[config.HashSize]byte]*Block)
+	chain.coinbaseMap = make(map[[config.HashSize]byte]uint64)
+	chain.blockIdxMap = make(map[[config.HashSize]byte]uint64)
+	chain.nullDataMap = make(map[uint64][]NullData)
+	chain.assetMap = make(map[AssetID]Asset)
+	chain.addressIndex = &addressIndex{entries: make(map[util.Address][]HistoryEntry)}
 	chain.difficulty = diff
-	chain.AddressMap = make(map[rsa.PublicKey]map[UTXO]bool)
+	chain.clock = clock
+	chain.AddressMap = make(map[util.Address]map[UTXO]bool)
 	chain.TransactionPool = make(map[string]*Transaction)
+	return chain
+}
+
+//InitializeBlockchain creates a blockchain from scratch with the given network parameters
+func InitializeBlockchain(params *config.ChainParams, gensisAddress util.Address) (Blockchain, error) {
+	diff, err := CreateDifficulty(params)
+	if err != nil {
+		return Blockchain{}, err
+	}
+	return InitializeBlockchainWithParams(params, gensisAddress, diff, util.RealClock{}), nil
+}
+
+//InitializeBlockchainWithDiff creates a regtest blockchain from scratch with a specific difficulty
+func InitializeBlockchainWithDiff(gensisAddress util.Address, diff Difficulty) Blockchain {
+	return InitializeBlockchainWithParams(&config.RegTestParams, gensisAddress, diff, util.RealClock{})
+}
 
-	gensisBlock := CreateFirstBlock(uint64(time.Now().UnixNano()/1000000), gensisAddress)
+//InitializeBlockchainWithParams creates a blockchain from scratch with the given network parameters, difficulty and clock
+func InitializeBlockchainWithParams(params *config.ChainParams, gensisAddress util.Address, diff Difficulty, clock util.Clock) Blockchain {
+	chain := createEmptyBlockchain(params, diff, clock)
+
+	gensisBlock := CreateFirstBlock(chain.params, clock.NowMs(), gensisAddress)
 	chain.performMinerTransactionAndAddBlock(gensisBlock)
 
 	return chain
 }
 
-/*
- * CreateICOTransaction vests amount of coins to speific users
- * currently I use miner to vest the coin, but need a better thought
- */
-/* func (chain *Blockchain) PopulateICOTransaction(from_address rsa.PublicKey, from_key *rsa.PrivateKey, to rsa.PublicKey, amount uint64) {
-	tx, err := chain.TransferCoin(&from_address, &to, config.MinerRewardBase/4, 500)
+//InitializeBlockchainFromGenesis creates a blockchain from a genesis spec.
+//The same spec always produces the same genesis block, so nodes sharing it share the chain.
+func InitializeBlockchainFromGenesis(spec *GenesisSpec) (Blockchain, error) {
+	return InitializeBlockchainFromGenesisWithClock(spec, util.RealClock{})
+}
+
+//InitializeBlockchainFromGenesisWithClock creates a blockchain from a genesis spec, validating blocks against the clock
+func InitializeBlockchainFromGenesisWithClock(spec *GenesisSpec, clock util.Clock) (Blockchain, error) {
+	gensisBlock, err := CreateGenesisBlock(spec)
+	if err != nil {
+		return Blockchain{}, err
+	}
+
+	params, _ := spec.Params()
+	diff, err := CreateDifficulty(params)
 	if err != nil {
-		util.GetBoosterLogger().Errorf("%v\n", err)
-		return
+		return Blockchain{}, err
 	}
-	tx.SignTransaction([]*rsa.PrivateKey{from_key})
+	chain := createEmptyBlockchain(params, diff, clock)
+	chain.chainID = spec.ChainID
+	chain.performMinerTransactionAndAddBlock(gensisBlock)
 
-	util.GetBoosterLogger().Debugf("%s\n", tx.Print())
-	chain.AcceptBroadcastedTransaction(tx)
-} */
+	return chain, nil
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"errors"
+
+	"../config"
+	"../util"
+)
+
+//GenerateBlocks Instantly mine n blocks rewarding address, only for chains whose difficulty is always met (regtest).
+//If includePool is set, the valid Transactions of the pool are confirmed and their fee goes to address.
+//Return the hashes of the new blocks.
+func (chain *Blockchain) GenerateBlocks(n int, address util.Address, includePool bool) ([][config.HashSize]byte, error) {
+	if chain.params.DifficultyAlgorithm != config.DifficultyNone {
+		return nil, errors.New("Blocks can only be generated on a chain without difficulty")
+	}
+
+	var hashes [][config.HashSize]byte
+	for i := 0; i < n; i++ {
+		prevBlock := chain.GetLatestBlock()
+		timeStampMs := prevBlock.timeStampMs + chain.params.TargetBlockIntervalMs
+
+		var trans []Transaction
+		var fee uint64
+		if includePool {
+			trans, fee = chain.SelectPoolTransactions(timeStampMs)
+		}
+
+		block := createBlock(chain.params, prevBlock.hash, prevBlock.blockIdx+1, timeStampMs, address, trans)
+		block.Transactions[0].Outputs[0].Value += fee
+		block.FinalizeBlockAt(0, timeStampMs)
+
+		if err := chain.AddBlock(block); err != nil {
+			return hashes, err
+		}
+		hashes = append(hashes, block.hash)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,520 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"../config"
+	"../util"
+)
+
+//Channel is one side of a payment channel between two parties.
+//The funder locks the capacity in a 2-of-2 multisig output, then both parties update their balances
+//off-chain by exchanging signatures of commitment transactions spending it.
+//Only the funding transaction and the closing (or a commitment) transaction go to the chain.
+//
+//Each party holds its own commitment signed by the counterparty. The output paying the holder is
+//locked for Delay blocks so that, if the holder broadcasts a state it has revoked, the counterparty
+//can take it with the revocation secret revealed when the state was replaced.
+//
+//The funding output isn't paid to a public key hash, which Transaction.SignTransaction requires, so its
+//spending transactions are signed with SignMultiSigInput, and a commitment carries the bare signature
+//(SignatureOf) of the counterparty until its holder adds its own.
+type Channel struct {
+	chain         *Blockchain
+	local         util.PrivateKey
+	remote        util.PublicKey
+	isFunder      bool
+	fundingScript Script
+	fundingTxID   [config.HashSize]byte
+	fundingIndex  uint32
+
+	Capacity      uint64
+	Fee           uint64 /* of the commitment and closing transactions, paid by the funder */
+	Delay         uint32 /* dispute timeout in blocks of a unilateral close */
+	State         uint64
+	LocalBalance  uint64
+	RemoteBalance uint64
+
+	localSecrets  map[uint64][]byte                /* revocation secrets of our commitments */
+	remoteHashes  map[uint64][config.HashSize]byte /* revocation hashes of their commitments */
+	remoteSecrets map[uint64][]byte                /* secrets of the states they revoked */
+	commitment    *Transaction                     /* our latest commitment, signed by them */
+	pending       *ChannelCommitment               /* update we signed and wait for them to acknowledge */
+}
+
+//ChannelOpen is sent by the funder to open a channel
+type ChannelOpen struct {
+	FundingTxID      [config.HashSize]byte
+	FundingIndex     uint32
+	Capacity         uint64
+	Fee              uint64
+	Delay            uint32
+	FunderKey        util.PublicKey
+	RevocationHashes [2][config.HashSize]byte /* of the first two commitments of the funder */
+}
+
+//ChannelAccept is the answer of the counterparty to ChannelOpen
+type ChannelAccept struct {
+	RevocationHashes [2][config.HashSize]byte
+	Commitment       ChannelCommitment
+}
+
+//ChannelCommitment carries the signature of the sender for the commitment of the receiver at State.
+//Balances are seen from the sender.
+type ChannelCommitment struct {
+	State           uint64
+	SenderBalance   uint64
+	ReceiverBalance uint64
+	Signature       []byte
+}
+
+//ChannelRevocation gives up the commitment of the sender at State, and announces the
+//revocation hash of its commitment after the next one
+type ChannelRevocation struct {
+	State    uint64
+	Secret   []byte
+	NextHash [config.HashSize]byte
+}
+
+func newChannel(chain *Blockchain, local util.PrivateKey, remote util.PublicKey, isFunder bool) (*Channel, error) {
+	keys := []util.PublicKey{local.Public(), remote}
+	if !isFunder {
+		keys[0], keys[1] = keys[1], keys[0]
+	}
+	script, err := MultiSigScript(2, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Channel{
+		chain:         chain,
+		local:         local,
+		remote:        remote,
+		isFunder:      isFunder,
+		fundingScript: script,
+		localSecrets:  make(map[uint64][]byte),
+		remoteHashes:  make(map[uint64][config.HashSize]byte),
+		remoteSecrets: make(map[uint64][]byte),
+	}, nil
+}
+
+//OpenChannel Fund a channel with remote by capacity of the coins of the key.
+//The funding transaction is returned unsigned: sign and broadcast it only after CompleteOpen
+//so the coins can't be locked without a commitment to get them back.
+func (chain *Blockchain) OpenChannel(local util.PrivateKey, remote util.PublicKey, capacity uint64, fee uint64, delay uint32) (*Channel, *ChannelOpen, *Transaction, error) {
+	if capacity <= fee {
+		return nil, nil, nil, errors.New("Channel capacity must exceed the fee")
+	}
+	channel, err := newChannel(chain, local, remote, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	funder := util.NewAddress(chain.params.PubKeyHashAddrID, local.Public())
+	fundingTx, err := chain.TransferToScript(funder, channel.fundingScript, capacity, fee)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	channel.fundingTxID = fundingTx.TxID()
+	channel.Capacity = capacity
+	channel.Fee = fee
+	channel.Delay = delay
+	channel.LocalBalance = capacity - fee
+
+	open := ChannelOpen{
+		FundingTxID:  channel.fundingTxID,
+		FundingIndex: channel.fundingIndex,
+		Capacity:     capacity,
+		Fee:          fee,
+		Delay:        delay,
+		FunderKey:    local.Public(),
+	}
+	for state := uint64(0); state < 2; state++ {
+		if open.RevocationHashes[state], err = channel.newRevocationHash(state); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return channel, &open, fundingTx, nil
+}
+
+//AcceptChannel Join the channel opened by open and sign the first commitment of the funder
+func (chain *Blockchain) AcceptChannel(local util.PrivateKey, open *ChannelOpen) (*Channel, *ChannelAccept, error) {
+	if open.Capacity <= open.Fee {
+		return nil, nil, errors.New("Channel capacity must exceed the fee")
+	}
+	channel, err := newChannel(chain, local, open.FunderKey, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channel.fundingTxID = open.FundingTxID
+	channel.fundingIndex = open.FundingIndex
+	channel.Capacity = open.Capacity
+	channel.Fee = open.Fee
+	channel.Delay = open.Delay
+	channel.RemoteBalance = open.Capacity - open.Fee
+	channel.remoteHashes[0] = open.RevocationHashes[0]
+	channel.remoteHashes[1] = open.RevocationHashes[1]
+
+	var accept ChannelAccept
+	for state := uint64(0); state < 2; state++ {
+		if accept.RevocationHashes[state], err = channel.newRevocationHash(state); err != nil {
+			return nil, nil, err
+		}
+	}
+	if accept.Commitment, err = channel.signRemoteCommitment(0, channel.LocalBalance, channel.RemoteBalance); err != nil {
+		return nil, nil, err
+	}
+	return channel, &accept, nil
+}
+
+//CompleteOpen Check the signature of our first commitment and sign the first commitment of the counterparty.
+//The funding transaction can be broadcast afterwards.
+func (channel *Channel) CompleteOpen(accept *ChannelAccept) (*ChannelCommitment, error) {
+	if !channel.isFunder || channel.commitment != nil {
+		return nil, errors.New("Channel is not being opened by us")
+	}
+	channel.remoteHashes[0] = accept.RevocationHashes[0]
+	channel.remoteHashes[1] = accept.RevocationHashes[1]
+
+	if err := channel.acceptCommitment(&accept.Commitment); err != nil {
+		return nil, err
+	}
+	commitment, err := channel.signRemoteCommitment(0, channel.LocalBalance, channel.RemoteBalance)
+	if err != nil {
+		return nil, err
+	}
+	return &commitment, nil
+}
+
+//ReceiveOpenCommitment Check the signature of our first commitment sent by the funder
+func (channel *Channel) ReceiveOpenCommitment(commitment *ChannelCommitment) error {
+	if channel.isFunder || channel.commitment != nil || commitment.State != 0 {
+		return errors.New("Channel is not being opened by the counterparty")
+	}
+	return channel.acceptCommitment(commitment)
+}
+
+//Pay Move amount of our balance to the counterparty, and sign its commitment of the new state
+func (channel *Channel) Pay(amount uint64) (*ChannelCommitment, error) {
+	if channel.commitment == nil {
+		return nil, errors.New("Channel is not open")
+	}
+	if channel.pending != nil {
+		return nil, errors.New("Channel has an update in progress")
+	}
+	if amount == 0 || amount > channel.LocalBalance {
+		return nil, fmt.Errorf("Channel balance %d can't pay %d", channel.LocalBalance, amount)
+	}
+
+	commitment, err := channel.signRemoteCommitment(channel.State+1, channel.LocalBalance-amount, channel.RemoteBalance+amount)
+	if err != nil {
+		return nil, err
+	}
+	channel.pending = &commitment
+	return &commitment, nil
+}
+
+//ReceivePayment Accept a new state paying us, sign the commitment of the counterparty for it
+//and revoke our previous commitment
+func (channel *Channel) ReceivePayment(commitment *ChannelCommitment) (*ChannelCommitment, *ChannelRevocation, error) {
+	if channel.commitment == nil {
+		return nil, nil, errors.New("Channel is not open")
+	}
+	if channel.pending != nil {
+		return nil, nil, errors.New("Channel has an update in progress")
+	}
+	if commitment.State != channel.State+1 || commitment.ReceiverBalance <= channel.LocalBalance {
+		return nil, nil, errors.New("Channel update doesn't pay us")
+	}
+
+	if err := channel.acceptCommitment(commitment); err != nil {
+		return nil, nil, err
+	}
+	reply, err := channel.signRemoteCommitment(channel.State, channel.LocalBalance, channel.RemoteBalance)
+	if err != nil {
+		return nil, nil, err
+	}
+	revocation, err := channel.revoke(channel.State - 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &reply, revocation, nil
+}
+
+//ReceivePaymentAck Take the signature of our commitment for the state we proposed and the revocation
+//of the previous state of the counterparty, then revoke our previous commitment
+func (channel *Channel) ReceivePaymentAck(commitment *ChannelCommitment, revocation *ChannelRevocation) (*ChannelRevocation, error) {
+	if channel.pending == nil {
+		return nil, errors.New("Channel has no update in progress")
+	}
+	pending := channel.pending
+	if commitment.State != pending.State || commitment.SenderBalance != pending.ReceiverBalance ||
+		commitment.ReceiverBalance != pending.SenderBalance {
+		return nil, errors.New("Channel update doesn't match the one we proposed")
+	}
+	if revocation.State+1 != pending.State {
+		return nil, errors.New("Channel revocation is not for the previous state")
+	}
+	if err := channel.receiveRevocation(revocation); err != nil {
+		return nil, err
+	}
+	if err := channel.acceptCommitment(commitment); err != nil {
+		return nil, err
+	}
+	channel.pending = nil
+	return channel.revoke(channel.State - 1)
+}
+
+//ReceiveRevocation Take the revocation of the previous state of the counterparty, completing a payment to us
+func (channel *Channel) ReceiveRevocation(revocation *ChannelRevocation) error {
+	if revocation.State+1 != channel.State {
+		return errors.New("Channel revocation is not for the previous state")
+	}
+	return channel.receiveRevocation(revocation)
+}
+
+//CloseProposal Make the transaction paying both balances from the funding output, signed by us
+func (channel *Channel) CloseProposal() (*Transaction, error) {
+	if channel.commitment == nil || channel.pending != nil {
+		return nil, errors.New("Channel can't be closed during an update")
+	}
+	tx := channel.closingTransaction()
+	if err := tx.SignMultiSigInput(0, channel.fundingScript, channel.local); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+//AcceptClose Check a closing transaction proposed by the counterparty pays the current balances
+//and complete it with our signature
+func (channel *Channel) AcceptClose(proposal *Transaction) (*Transaction, error) {
+	if channel.commitment == nil || channel.pending != nil {
+		return nil, errors.New("Channel can't be closed during an update")
+	}
+	expected := channel.closingTransaction()
+	if !bytes.Equal(proposal.GetRawDataToHash(), expected.GetRawDataToHash()) {
+		return nil, errors.New("Closing transaction doesn't pay the channel balances")
+	}
+
+	tx := *proposal
+	tx.Inputs = append([]TransactionInput{}, proposal.Inputs...)
+	if err := tx.SignMultiSigInput(0, channel.fundingScript, channel.local); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+//ForceClose Get our latest commitment with both signatures, to close the channel without the counterparty.
+//Our balance can be swept by SweepCommitment Delay blocks after it is confirmed.
+func (channel *Channel) ForceClose() (*Transaction, error) {
+	if channel.commitment == nil {
+		return nil, errors.New("Channel is not open")
+	}
+	tx := *channel.commitment
+	tx.Inputs = append([]TransactionInput{}, channel.commitment.Inputs...)
+	if err := tx.SignMultiSigInput(0, channel.fundingScript, channel.local); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+//SweepCommitment Make a signed transaction taking our balance from our commitment once the dispute timeout passed
+func (channel *Channel) SweepCommitment(commitment *Transaction, fee uint64) (*Transaction, error) {
+	for _, secret := range channel.localSecrets {
+		script := channel.toSelfScript(sha256.Sum256(secret), channel.local.Public(), channel.remote)
+		unlock := NewScriptBuilder().AddInt(0).Script()
+		if tx, err := channel.spendCommitment(commitment, script, channel.Delay, unlock, fee); err == nil {
+			return tx, nil
+		}
+	}
+	return nil, errors.New("Transaction is not one of our commitments")
+}
+
+//Punish Make a signed transaction taking the balance of the counterparty from a commitment it revoked
+func (channel *Channel) Punish(commitment *Transaction, fee uint64) (*Transaction, error) {
+	for _, secret := range channel.remoteSecrets {
+		script := channel.toSelfScript(sha256.Sum256(secret), channel.remote, channel.local.Public())
+		unlock := NewScriptBuilder().AddData(secret).AddInt(1).Script()
+		if tx, err := channel.spendCommitment(commitment, script, 0, unlock, fee); err == nil {
+			return tx, nil
+		}
+	}
+	return nil, errors.New("Transaction is not a revoked commitment of the counterparty")
+}
+
+/*
+ * The output paying the holder of a commitment:
+ * IF SHA256 <revocation hash> EQUALVERIFY DUP HASH20 <counterparty> ELSE <delay> CSV DROP DUP HASH20 <holder> ENDIF EQUALVERIFY CHECKSIG
+ */
+func (channel *Channel) toSelfScript(revocationHash [config.HashSize]byte, holder util.PublicKey, counterparty util.PublicKey) Script {
+	holderHash := util.Hash20(holder.Bytes())
+	counterpartyHash := util.Hash20(counterparty.Bytes())
+	return NewScriptBuilder().
+		AddOp(OpIf).
+		AddOp(OpSha256).AddData(revocationHash[:]).AddOp(OpEqualVerify).
+		AddOp(OpDup).AddOp(OpHash20).AddData(counterpartyHash[:]).
+		AddOp(OpElse).
+		AddInt(uint64(channel.Delay)).AddOp(OpCheckSequenceVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpHash20).AddData(holderHash[:]).
+		AddOp(OpEndIf).
+		AddOp(OpEqualVerify).AddOp(OpCheckSig).
+		Script()
+}
+
+func (channel *Channel) address(pub util.PublicKey) util.Address {
+	return util.NewAddress(channel.chain.params.PubKeyHashAddrID, pub)
+}
+
+/*
+ * The commitment of holder pays its balance to its delayed output and the balance of the
+ * counterparty directly. Empty balances get no output.
+ */
+func (channel *Channel) commitmentTransaction(revocationHash [config.HashSize]byte, holder util.PublicKey, counterparty util.PublicKey, holderBalance uint64, counterpartyBalance uint64) *Transaction {
+	tx := CreateTransaction(1, 0)
+	tx.Inputs[0].PrevtxMap = channel.fundingTxID
+	tx.Inputs[0].OutputIndex = channel.fundingIndex
+
+	if holderBalance > 0 {
+		var output TransactionOutput
+		output.LockWithScript(channel.toSelfScript(revocationHash, holder, counterparty), channel.chain.params)
+		output.Value = holderBalance
+		tx.Outputs = append(tx.Outputs, output)
+	}
+	if counterpartyBalance > 0 {
+		tx.Outputs = append(tx.Outputs, TransactionOutput{Value: counterpartyBalance, Address: channel.address(counterparty)})
+	}
+	tx.Sender = channel.chain.ScriptAddress(channel.fundingScript)
+	return &tx
+}
+
+/*
+ * The closing transaction pays the funder first
+ */
+func (channel *Channel) closingTransaction() *Transaction {
+	tx := CreateTransaction(1, 0)
+	tx.Inputs[0].PrevtxMap = channel.fundingTxID
+	tx.Inputs[0].OutputIndex = channel.fundingIndex
+
+	balances := []uint64{channel.LocalBalance, channel.RemoteBalance}
+	keys := []util.PublicKey{channel.local.Public(), channel.remote}
+	if !channel.isFunder {
+		balances[0], balances[1] = balances[1], balances[0]
+		keys[0], keys[1] = keys[1], keys[0]
+	}
+	for i := range balances {
+		if balances[i] > 0 {
+			tx.Outputs = append(tx.Outputs, TransactionOutput{Value: balances[i], Address: channel.address(keys[i])})
+		}
+	}
+	tx.Sender = channel.chain.ScriptAddress(channel.fundingScript)
+	return &tx
+}
+
+func (channel *Channel) newRevocationHash(state uint64) ([config.HashSize]byte, error) {
+	secret := make([]byte, config.HashSize)
+	if _, err := rand.Read(secret); err != nil {
+		return [config.HashSize]byte{}, err
+	}
+	channel.localSecrets[state] = secret
+	return sha256.Sum256(secret), nil
+}
+
+func (channel *Channel) signRemoteCommitment(state uint64, localBalance uint64, remoteBalance uint64) (ChannelCommitment, error) {
+	if localBalance+remoteBalance+channel.Fee != channel.Capacity {
+		return ChannelCommitment{}, errors.New("Channel balances don't add up to its capacity")
+	}
+	revocationHash, ok := channel.remoteHashes[state]
+	if !ok {
+		return ChannelCommitment{}, fmt.Errorf("Channel has no revocation hash of the counterparty for state %d", state)
+	}
+
+	tx := channel.commitmentTransaction(revocationHash, channel.remote, channel.local.Public(), remoteBalance, localBalance)
+	signature, err := tx.SignatureOf(0, SigHashAll, channel.local)
+	if err != nil {
+		return ChannelCommitment{}, err
+	}
+	return ChannelCommitment{State: state, SenderBalance: localBalance, ReceiverBalance: remoteBalance, Signature: signature}, nil
+}
+
+/*
+ * Check the signature of the counterparty for our commitment, and move to its state
+ */
+func (channel *Channel) acceptCommitment(commitment *ChannelCommitment) error {
+	if commitment.SenderBalance+commitment.ReceiverBalance+channel.Fee != channel.Capacity {
+		return errors.New("Channel balances don't add up to its capacity")
+	}
+	secret, ok := channel.localSecrets[commitment.State]
+	if !ok {
+		return fmt.Errorf("Channel has no revocation secret for state %d", commitment.State)
+	}
+
+	tx := channel.commitmentTransaction(sha256.Sum256(secret), channel.local.Public(), channel.remote,
+		commitment.ReceiverBalance, commitment.SenderBalance)
+	if !tx.VerifyInputSignature(0, nil, commitment.Signature, channel.remote) {
+		return errors.New("Commitment signature of the counterparty is invalid")
+	}
+	tx.Inputs[0].Script = MultiSigSignatureScript([][]byte{commitment.Signature})
+
+	channel.commitment = tx
+	channel.State = commitment.State
+	channel.LocalBalance = commitment.ReceiverBalance
+	channel.RemoteBalance = commitment.SenderBalance
+	return nil
+}
+
+/*
+ * Reveal the secret of our commitment at state, and make the revocation hash of the one after the next
+ */
+func (channel *Channel) revoke(state uint64) (*ChannelRevocation, error) {
+	nextHash, err := channel.newRevocationHash(state + 2)
+	if err != nil {
+		return nil, err
+	}
+	return &ChannelRevocation{State: state, Secret: channel.localSecrets[state], NextHash: nextHash}, nil
+}
+
+func (channel *Channel) receiveRevocation(revocation *ChannelRevocation) error {
+	if sha256.Sum256(revocation.Secret) != channel.remoteHashes[revocation.State] {
+		return errors.New("Revocation secret doesn't match the commitment")
+	}
+	channel.remoteSecrets[revocation.State] = revocation.Secret
+	channel.remoteHashes[revocation.State+2] = revocation.NextHash
+	return nil
+}
+
+/*
+ * Spend the output of commitment locked by script to us, with <signature> <public key> followed by unlock
+ */
+func (channel *Channel) spendCommitment(commitment *Transaction, script Script, sequence uint32, unlock Script, fee uint64) (*Transaction, error) {
+	scriptAddress := channel.chain.ScriptAddress(script)
+	for i, output := range commitment.Outputs {
+		if output.Address != scriptAddress {
+			continue
+		}
+		if output.Value <= fee {
+			return nil, errors.New("Commitment output can't pay the fee")
+		}
+
+		tx := CreateTransaction(1, 1)
+		tx.Inputs[0].PrevtxMap = commitment.TxID()
+		tx.Inputs[0].OutputIndex = uint32(i)
+		tx.Inputs[0].Sequence = sequence
+		tx.Outputs[0].Address = channel.address(channel.local.Public())
+		tx.Outputs[0].Value = output.Value - fee
+		tx.Sender = tx.Outputs[0].Address
+
+		signature, err := tx.SignatureOf(0, SigHashAll, channel.local)
+		if err != nil {
+			return nil, err
+		}
+		tx.Inputs[0].Script = append(SignatureScript(signature, channel.local.Public()), unlock...)
+		return &tx, nil
+	}
+	return nil, errors.New("Commitment has no output locked by the script")
+}
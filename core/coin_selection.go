@@ -0,0 +1,262 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+//bnbMaxTries bounds the search of BranchAndBound, it falls back afterwards
+const bnbMaxTries = 100000
+
+//Coin an unspent output a transaction may spend
+type Coin struct {
+	UTXO  UTXO
+	Value uint64
+}
+
+//CoinTarget what the selected coins must pay. The fee of a transaction grows with its inputs and
+//with the change output, the change is only worth an output from MinChange on.
+type CoinTarget struct {
+	Amount      uint64 /* paid to the recipients */
+	Fee         uint64 /* fee of the transaction without inputs and change */
+	FeePerInput uint64 /* fee added by each input */
+	ChangeFee   uint64 /* fee added by the change output */
+	MinChange   uint64 /* smaller change is left to the fee instead */
+}
+
+//CoinSelection the coins selected for a target, with the change and the estimated fee they make
+type CoinSelection struct {
+	Coins  []Coin
+	Total  uint64 /* value of the coins */
+	Change uint64 /* 0 if there is no change output */
+	Fee    uint64 /* including what was left to the fee instead of making change */
+}
+
+//CoinSelector a strategy choosing which coins pay a target. At least one coin is selected,
+//as a transaction needs an input.
+type CoinSelector interface {
+	SelectCoins(coins []Coin, target CoinTarget) (*CoinSelection, error)
+}
+
+//LargestFirst spend the largest coins first, making small transactions
+type LargestFirst struct{}
+
+//SmallestFirst spend the smallest coins first, consolidating them
+type SmallestFirst struct{}
+
+//BranchAndBound search coins paying the target without change, which saves the change output
+//and doesn't tell which output is the change. Fallback selects when there is no such coins,
+//LargestFirst if it is nil.
+type BranchAndBound struct {
+	Fallback CoinSelector
+}
+
+//RandomImprove pick random coins until the target is reached, then add random coins while they bring
+//the change closer to the amount paid, so the change looks like a payment and can pay similar amounts later.
+//Rand is the source of randomness, the default source of math/rand if it is nil.
+type RandomImprove struct {
+	Rand *rand.Rand
+}
+
+/*
+ * Value a coin adds to the selection once the fee of its input is paid
+ */
+func (target CoinTarget) effectiveValue(coin Coin) uint64 {
+	if coin.Value <= target.FeePerInput {
+		return 0
+	}
+	return coin.Value - target.FeePerInput
+}
+
+/*
+ * Value the coins must reach before paying the change
+ */
+func (target CoinTarget) need(inputs int) uint64 {
+	return target.Amount + target.Fee + uint64(inputs)*target.FeePerInput
+}
+
+/*
+ * Smallest excess over the need which makes a change output
+ */
+func (target CoinTarget) changeThreshold() uint64 {
+	if target.MinChange == 0 {
+		return target.ChangeFee + 1
+	}
+	return target.ChangeFee + target.MinChange
+}
+
+/*
+ * Compute the change and the fee of spending coins
+ */
+func newCoinSelection(coins []Coin, target CoinTarget) (*CoinSelection, error) {
+	selection := &CoinSelection{Coins: coins}
+	for _, coin := range coins {
+		selection.Total += coin.Value
+	}
+	need := target.need(len(coins))
+	if len(coins) == 0 || selection.Total < need {
+		return nil, fmt.Errorf("Insufficient funds: %d available, %d needed", selection.Total, need)
+	}
+
+	excess := selection.Total - need
+	selection.Fee = selection.Total - target.Amount
+	if excess >= target.changeThreshold() {
+		selection.Change = excess - target.ChangeFee
+		selection.Fee -= selection.Change
+	}
+	return selection, nil
+}
+
+/*
+ * Sort the coins by value, the order of coins of the same value is fixed by their outpoint
+ */
+func sortCoins(coins []Coin, descending bool) []Coin {
+	sorted := append([]Coin{}, coins...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Value != sorted[j].Value {
+			return (sorted[i].Value > sorted[j].Value) == descending
+		}
+		if cmp := bytes.Compare(sorted[i].UTXO.txMap[:], sorted[j].UTXO.txMap[:]); cmp != 0 {
+			return cmp < 0
+		}
+		return sorted[i].UTXO.outputIndex < sorted[j].UTXO.outputIndex
+	})
+	return sorted
+}
+
+/*
+ * Select the coins in order until they reach the target, skipping those not worth their input
+ */
+func selectInOrder(coins []Coin, target CoinTarget) (*CoinSelection, error) {
+	var selected []Coin
+	var total uint64
+	for _, coin := range coins {
+		if len(selected) > 0 && total >= target.need(len(selected)) {
+			break
+		}
+		if target.effectiveValue(coin) == 0 {
+			continue
+		}
+		selected = append(selected, coin)
+		total += coin.Value
+	}
+	return newCoinSelection(selected, target)
+}
+
+//SelectCoins Select the largest coins until they reach the target
+func (LargestFirst) SelectCoins(coins []Coin, target CoinTarget) (*CoinSelection, error) {
+	return selectInOrder(sortCoins(coins, true), target)
+}
+
+//SelectCoins Select the smallest coins until they reach the target
+func (SmallestFirst) SelectCoins(coins []Coin, target CoinTarget) (*CoinSelection, error) {
+	return selectInOrder(sortCoins(coins, false), target)
+}
+
+//SelectCoins Search the coins paying the target with the least excess and no change,
+//or let the fallback select
+func (selector BranchAndBound) SelectCoins(coins []Coin, target CoinTarget) (*CoinSelection, error) {
+	/* The effective values already pay the fee of the inputs */
+	var candidates []Coin
+	var remaining uint64
+	for _, coin := range sortCoins(coins, true) {
+		if value := target.effectiveValue(coin); value > 0 {
+			candidates = append(candidates, coin)
+			remaining += value
+		}
+	}
+	need := target.need(0)
+	threshold := target.changeThreshold()
+
+	var best, selected []Coin
+	var bestExcess uint64
+	tries := 0
+	var search func(i int, total uint64, remaining uint64)
+	search = func(i int, total uint64, remaining uint64) {
+		if tries >= bnbMaxTries {
+			return
+		}
+		tries++
+		if total >= need && len(selected) > 0 {
+			/* Any other coin would only add to the excess */
+			if excess := total - need; excess < threshold && (best == nil || excess < bestExcess) {
+				best = append([]Coin{}, selected...)
+				bestExcess = excess
+			}
+			return
+		}
+		if i == len(candidates) || total+remaining < need {
+			return
+		}
+
+		value := target.effectiveValue(candidates[i])
+		selected = append(selected, candidates[i])
+		search(i+1, total+value, remaining-value)
+		selected = selected[:len(selected)-1]
+		if best != nil && bestExcess == 0 {
+			return
+		}
+		/* Leaving out a coin then taking one of the same value is the same selection */
+		next := i + 1
+		for next < len(candidates) && candidates[next].Value == candidates[i].Value {
+			remaining -= value
+			next++
+		}
+		search(next, total, remaining-value)
+	}
+	search(0, 0, remaining)
+
+	if best != nil {
+		return newCoinSelection(best, target)
+	}
+	fallback := selector.Fallback
+	if fallback == nil {
+		fallback = LargestFirst{}
+	}
+	return fallback.SelectCoins(coins, target)
+}
+
+//SelectCoins Select random coins reaching the target, then improve the change with more random coins
+func (selector RandomImprove) SelectCoins(coins []Coin, target CoinTarget) (*CoinSelection, error) {
+	shuffled := sortCoins(coins, true)
+	shuffle := rand.Shuffle
+	if selector.Rand != nil {
+		shuffle = selector.Rand.Shuffle
+	}
+	shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	var selected []Coin
+	var total uint64
+	i := 0
+	for ; i < len(shuffled) && (len(selected) == 0 || total < target.need(len(selected))); i++ {
+		if target.effectiveValue(shuffled[i]) > 0 {
+			selected = append(selected, shuffled[i])
+			total += shuffled[i].Value
+		}
+	}
+	if len(selected) == 0 || total < target.need(len(selected)) {
+		return newCoinSelection(selected, target)
+	}
+
+	/* Aim at a change as large as the amount, without spending more than twice the amount in change */
+	distance := func(change uint64) uint64 {
+		if change > target.Amount {
+			return change - target.Amount
+		}
+		return target.Amount - change
+	}
+	for ; i < len(shuffled); i++ {
+		value := target.effectiveValue(shuffled[i])
+		change := total - target.need(len(selected))
+		if value == 0 || change+value > 2*target.Amount || distance(change+value) >= distance(change) {
+			continue
+		}
+		selected = append(selected, shuffled[i])
+		total += shuffled[i].Value
+	}
+	return newCoinSelection(selected, target)
+}
@@ -1,8 +1,9 @@
 package core
 
 import (
-	"crypto/rsa"
 	"encoding/binary"
+
+	"../util"
 )
 
 func appendUint32(data []byte, Value uint32) []byte {
@@ -17,11 +18,36 @@ func appendUint64(data []byte, Value uint64) []byte {
 	return append(data, b...)
 }
 
-func appendAddress(data []byte, key *rsa.PublicKey) []byte {
-	data = appendUint32(data, uint32(key.E))
-	keyBytes := key.N.Bytes()
-	data = appendUint32(data, uint32(len(keyBytes)))
-	return append(data, keyBytes...)
+func appendAddress(data []byte, address util.Address) []byte {
+	return append(data, address[:]...)
+}
+
+func appendScript(data []byte, script Script) []byte {
+	data = appendUint32(data, uint32(len(script)))
+	return append(data, script...)
+}
+
+func appendInput(data []byte, input *TransactionInput, withWitness bool) []byte {
+	data = appendUint32(data, input.OutputIndex)
+	data = append(data, input.PrevtxMap[:]...)
+	data = appendUint32(data, input.Sequence)
+	if withWitness {
+		data = appendScript(data, input.Script)
+	}
+	return data
+}
+
+func appendOutput(data []byte, output *TransactionOutput) []byte {
+	data = appendUint64(data, output.Value)
+	data = appendAddress(data, output.Address)
+	data = appendScript(data, output.Script)
+	return append(data, output.Asset[:]...)
+}
+
+func appendIssuance(data []byte, issuance AssetIssuance) []byte {
+	data = appendUint32(data, uint32(len(issuance.Name)))
+	data = append(data, issuance.Name...)
+	return appendUint64(data, issuance.Amount)
 }
 
 func appendUint256(data []byte, Value uint256) []byte {
@@ -33,6 +33,40 @@ type MADifficulty struct {
 	difficulty            [config.HashSize]byte
 }
 
+//NoDifficulty A difficulty that every hash reaches, used by regtest chains.
+type NoDifficulty struct {
+}
+
+//CreateDifficulty Create the difficulty configured by the chain parameters
+func CreateDifficulty(params *config.ChainParams) (Difficulty, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch params.DifficultyAlgorithm {
+	case config.DifficultySimple:
+		return CreateSimpleDifficulty(params.TargetBlockIntervalMs, params.InitialDifficulty), nil
+	case config.DifficultyMA:
+		return CreateMADifficulty(params.TargetBlockIntervalMs, params.InitialDifficulty, params.DifficultyMASamples), nil
+	}
+	return &NoDifficulty{}, nil
+}
+
+//ReachDifficulty Every hash reaches the difficulty
+func (d *NoDifficulty) ReachDifficulty(hash [config.HashSize]byte) bool {
+	return true
+}
+
+//UpdateDifficulty Nothing to update
+func (d *NoDifficulty) UpdateDifficulty(usedTimeMs uint64) error {
+	return nil
+}
+
+//Print details of a NoDifficulty
+func (d *NoDifficulty) Print() string {
+	return "NoDifficulty:[]"
+}
+
 //CreateSimpleDifficulty Create a 'SimpleDifficulty'
 func CreateSimpleDifficulty(targetBlockIntervalMs uint64, prob float64) Difficulty {
 	var diff SimpleDifficulty
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"../config"
+	"../util"
+)
+
+//GenesisAllocation premines coins to an address in the genesis block
+type GenesisAllocation struct {
+	Address string `json:"address"` /* Base58Check address, see util.Address */
+	Value   uint64 `json:"value"`
+}
+
+//GenesisDifficulty overrides the difficulty of the network parameters, zero values are inherited
+type GenesisDifficulty struct {
+	Algorithm             string  `json:"algorithm,omitempty"` /* "none", "simple" or "ma" */
+	TargetBlockIntervalMs uint64  `json:"targetBlockIntervalMs,omitempty"`
+	Prob                  float64 `json:"prob,omitempty"`
+	MASamples             uint32  `json:"maSamples,omitempty"` /* only used by "ma" */
+}
+
+//GenesisSpec describes the genesis block of a chain.
+//Every node loading the same spec ends up with the same genesis hash.
+type GenesisSpec struct {
+	ChainID     string              `json:"chainId"`
+	Network     string              `json:"network"` /* name of the network parameters, mainnet if empty */
+	TimeStampMs uint64              `json:"timeStampMs"`
+	Difficulty  GenesisDifficulty   `json:"difficulty"`
+	Allocations []GenesisAllocation `json:"allocations"`
+}
+
+//LoadGenesisSpec Load a genesis spec from a JSON file
+func LoadGenesisSpec(path string) (*GenesisSpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGenesisSpec(data)
+}
+
+//ParseGenesisSpec Parse and validate a JSON genesis spec
+func ParseGenesisSpec(data []byte) (*GenesisSpec, error) {
+	var spec GenesisSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+//Validate Check the spec is complete and consistent
+func (spec *GenesisSpec) Validate() error {
+	if spec.ChainID == "" {
+		return errors.New("Genesis spec must have a chain id")
+	}
+
+	if len(spec.Allocations) == 0 {
+		return errors.New("Genesis spec must allocate coins to at least one address")
+	}
+
+	params, err := spec.Params()
+	if err != nil {
+		return err
+	}
+
+	var total uint64
+	for i, alloc := range spec.Allocations {
+		address, err := util.ParseAddress(alloc.Address)
+		if err != nil {
+			return fmt.Errorf("Invalid address in allocation %d: %s", i, err)
+		}
+		if address.Version() != params.PubKeyHashAddrID {
+			return fmt.Errorf("Address in allocation %d doesn't belong to %s", i, params.Name)
+		}
+		if alloc.Value == 0 {
+			return fmt.Errorf("Allocation %d has no value", i)
+		}
+		if total > math.MaxUint64-alloc.Value {
+			return errors.New("Total allocation overflows")
+		}
+		total += alloc.Value
+	}
+	return nil
+}
+
+//Params Get the network parameters of the spec with its difficulty overrides applied
+func (spec *GenesisSpec) Params() (*config.ChainParams, error) {
+	network := spec.Network
+	if network == "" {
+		network = config.MainNetParams.Name
+	}
+	params, err := config.ParamsByName(network)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Difficulty.Algorithm != "" {
+		params.DifficultyAlgorithm = spec.Difficulty.Algorithm
+	}
+	if spec.Difficulty.TargetBlockIntervalMs != 0 {
+		params.TargetBlockIntervalMs = spec.Difficulty.TargetBlockIntervalMs
+	}
+	if spec.Difficulty.Prob != 0 {
+		params.InitialDifficulty = spec.Difficulty.Prob
+	}
+	if spec.Difficulty.MASamples != 0 {
+		params.DifficultyMASamples = spec.Difficulty.MASamples
+	}
+
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+//CreateGenesisBlock create the genesis block described by a spec.
+//The premine is paid by the reward transaction, which commits to the chain id.
+func CreateGenesisBlock(spec *GenesisSpec) (*Block, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	reward := CreateTransaction(1, len(spec.Allocations))
+	reward.Inputs[0].PrevtxMap = sha256.Sum256([]byte(spec.ChainID))
+	for i, alloc := range spec.Allocations {
+		reward.Outputs[i].Address, _ = util.ParseAddress(alloc.Address)
+		reward.Outputs[i].Value = alloc.Value
+	}
+
+	var block Block
+	block.blockIdx = 0
+	block.timeStampMs = spec.TimeStampMs
+	block.minerAddress = reward.Outputs[0].Address
+	block.Transactions = []Transaction{reward}
+	block.hash = sha256.Sum256(block.getRawDataToHash())
+	return &block, nil
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"../config"
+	"../util"
+)
+
+//HTLC is a hash time-locked contract: the Recipient can claim the coins by revealing the preimage
+//of SecretHash, or the Sender can take them back once Timeout has passed.
+//Two HTLCs with the same SecretHash on two chains make an atomic swap.
+type HTLC struct {
+	SecretHash [config.HashSize]byte /* SHA-256 of the secret */
+	Recipient  util.Address
+	Sender     util.Address
+	Timeout    uint64 /* a height, or a timestamp in ms from config.LockTimeThreshold */
+}
+
+//Script Get the locking script of the contract.
+//It is claimed with <signature> <public key> <preimage> OP_1 and refunded with <signature> <public key> OP_0.
+func (htlc *HTLC) Script() Script {
+	recipient := htlc.Recipient.Hash()
+	sender := htlc.Sender.Hash()
+	return NewScriptBuilder().
+		AddOp(OpIf).
+		AddOp(OpSha256).AddData(htlc.SecretHash[:]).AddOp(OpEqualVerify).
+		AddOp(OpDup).AddOp(OpHash20).AddData(recipient[:]).
+		AddOp(OpElse).
+		AddInt(htlc.Timeout).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpHash20).AddData(sender[:]).
+		AddOp(OpEndIf).
+		AddOp(OpEqualVerify).AddOp(OpCheckSig).
+		Script()
+}
+
+//CreateHTLC Make a transaction locking amount of the coins of the Sender in the contract.
+//Note that the transaction is unsigned
+func (chain *Blockchain) CreateHTLC(htlc *HTLC, amount uint64, fee uint64) (*Transaction, error) {
+	return chain.TransferToScript(htlc.Sender, htlc.Script(), amount, fee)
+}
+
+//ClaimHTLC Make a signed transaction paying the coins locked in the contract to the Recipient
+func (chain *Blockchain) ClaimHTLC(htlc *HTLC, preimage []byte, recipient util.PrivateKey, fee uint64) (*Transaction, error) {
+	if sha256.Sum256(preimage) != htlc.SecretHash {
+		return nil, errors.New("Preimage doesn't match the secret hash of the contract")
+	}
+	unlock := NewScriptBuilder().AddData(preimage).AddInt(1).Script()
+	return chain.spendHTLC(htlc, htlc.Recipient, 0, recipient, unlock, fee)
+}
+
+//RefundHTLC Make a signed transaction paying the coins locked in the contract back to the Sender.
+//It stays in the pool until the contract times out.
+func (chain *Blockchain) RefundHTLC(htlc *HTLC, sender util.PrivateKey, fee uint64) (*Transaction, error) {
+	unlock := NewScriptBuilder().AddInt(0).Script()
+	return chain.spendHTLC(htlc, htlc.Sender, htlc.Timeout, sender, unlock, fee)
+}
+
+/*
+ * Spend all the coins locked in the contract to address, each unlocking script is the
+ * signature and the public key of signer followed by unlock.
+ */
+func (chain *Blockchain) spendHTLC(htlc *HTLC, to util.Address, lockTime uint64, signer util.PrivateKey, unlock Script, fee uint64) (*Transaction, error) {
+	if util.NewAddress(to.Version(), signer.Public()) != to {
+		return nil, fmt.Errorf("Key doesn't belong to %s", to)
+	}
+
+	/* The coins already spent by a claim or a refund of the pool are left out */
+	reserved := chain.poolSpends()
+	var utxoList []UTXO
+	var total uint64
+	for utxo := range chain.AddressMap[chain.ScriptAddress(htlc.Script())] {
+		output := &chain.txMap[utxo.txMap].Outputs[utxo.outputIndex]
+		if !output.Asset.IsNative() || reserved[utxo] {
+			continue
+		}
+		utxoList = append(utxoList, utxo)
+		total += output.Value
+	}
+	if total == 0 {
+		return nil, errors.New("No coins are locked in the contract")
+	}
+	if total <= fee {
+		return nil, fmt.Errorf("Locked coins don't cover the fee: %d <= %d", total, fee)
+	}
+
+	tx := CreateTransaction(len(utxoList), 1)
+	for i, utxo := range utxoList {
+		tx.Inputs[i].PrevtxMap = utxo.txMap
+		tx.Inputs[i].OutputIndex = utxo.outputIndex
+	}
+	tx.Outputs[0].Address = to
+	tx.Outputs[0].Value = total - fee
+	tx.LockTime = lockTime
+	tx.Sender = to
+
+	for i := range tx.Inputs {
+		signature, err := tx.SignatureOf(i, SigHashAll, signer)
+		if err != nil {
+			return nil, err
+		}
+		tx.Inputs[i].Script = append(SignatureScript(signature, signer.Public()), unlock...)
+	}
+	return &tx, nil
+}
+
+//FindHTLCSecret Look for the preimage of secretHash revealed by a claim, in the chain or in the pool
+func (chain *Blockchain) FindHTLCSecret(secretHash [config.HashSize]byte) ([]byte, bool) {
+	var trans []*Transaction
+	for _, tran := range chain.txMap {
+		trans = append(trans, tran)
+	}
+	for _, tran := range chain.TransactionPool {
+		trans = append(trans, tran)
+	}
+
+	for _, tran := range trans {
+		for _, input := range tran.Inputs {
+			ops, err := input.Script.parse()
+			if err != nil {
+				continue
+			}
+			for _, op := range ops {
+				if op.isPush() && sha256.Sum256(op.data) == secretHash {
+					return op.data, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
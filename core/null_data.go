@@ -0,0 +1,30 @@
+package core
+
+import (
+	"fmt"
+
+	"../config"
+	"../util"
+)
+
+//NullData is a payload anchored in the chain by a null-data output
+type NullData struct {
+	TxID        [config.HashSize]byte
+	OutputIndex uint32
+	Data        []byte
+}
+
+//AnchorData Make a transaction carrying data in a null-data output, paid by the coins of from.
+//Note that the transaction is unsigned
+func (chain *Blockchain) AnchorData(from util.Address, data []byte, fee uint64) (*Transaction, error) {
+	if len(data) > chain.params.MaxNullDataSize {
+		return nil, fmt.Errorf("Null data output exceeds %d bytes", chain.params.MaxNullDataSize)
+	}
+	tx, _, err := chain.transfer(from, nil, TransactionOutput{Script: NullDataScript(data)}, fee, nil)
+	return tx, err
+}
+
+//NullDataOf Get the payloads anchored in the block at blockIdx, in the order of the block
+func (chain *Blockchain) NullDataOf(blockIdx uint64) []NullData {
+	return chain.nullDataMap[blockIdx]
+}
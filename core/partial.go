@@ -0,0 +1,400 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"../config"
+	"../util"
+)
+
+const partialVersion = 1
+
+//PartialTransaction is a transaction passed between its signers until every input is signed,
+//e.g. as a file carried to an offline machine. It holds the outputs the inputs spend, so a signer
+//can check what they sign without the chain, and the signatures collected so far.
+//The signatures commit to these outputs (SigHashSpentOutput): if a signer is shown a forged one,
+//e.g. with a lower value hiding a large fee, the signature is invalid on the chain.
+//Inputs spending a pay to public key hash output or a multisig output can be signed.
+type PartialTransaction struct {
+	Tx     Transaction
+	Inputs []PartialInput /* one for each input of Tx */
+}
+
+//PartialInput the output spent by an input and the signatures of the input collected so far
+type PartialInput struct {
+	PrevOutput TransactionOutput
+	Signatures []PartialSignature
+}
+
+//PartialSignature a signature of an input (followed by its hash type) and the key which made it
+type PartialSignature struct {
+	PublicKey util.PublicKey
+	Signature []byte
+}
+
+/*
+ * File format of a partial transaction, addresses are in their text form
+ */
+type partialFile struct {
+	Version        int                 `json:"version"`
+	ID             string              `json:"id"`
+	Inputs         []partialFileInput  `json:"inputs"`
+	Outputs        []partialFileOutput `json:"outputs"`
+	LockTime       uint64              `json:"lockTime"`
+	IssuanceName   string              `json:"issuanceName,omitempty"`
+	IssuanceAmount uint64              `json:"issuanceAmount,omitempty"`
+	Sender         string              `json:"sender"`
+}
+
+type partialFileInput struct {
+	PrevTxID    []byte                 `json:"prevTxId"`
+	OutputIndex uint32                 `json:"outputIndex"`
+	Sequence    uint32                 `json:"sequence"`
+	Script      []byte                 `json:"script,omitempty"` /* set once finalized */
+	PrevOutput  partialFileOutput      `json:"prevOutput"`
+	Signatures  []partialFileSignature `json:"signatures,omitempty"`
+}
+
+type partialFileOutput struct {
+	Value   uint64 `json:"value"`
+	Address string `json:"address"`
+	Script  []byte `json:"script,omitempty"`
+	Asset   []byte `json:"asset,omitempty"` /* native coins if empty */
+}
+
+type partialFileSignature struct {
+	PublicKey []byte `json:"publicKey"`
+	Signature []byte `json:"signature"`
+}
+
+//CreatePartialTransaction Start collecting the signatures of an unsigned transaction,
+//prevOutputs are the outputs spent by its Inputs, in the same order
+func CreatePartialTransaction(tran Transaction, prevOutputs []TransactionOutput) (*PartialTransaction, error) {
+	if len(tran.Inputs) == 0 {
+		return nil, errors.New("Transaction has no input")
+	}
+	if len(prevOutputs) != len(tran.Inputs) {
+		return nil, errors.New("Number of previous outputs mismatch that of Inputs")
+	}
+
+	partial := &PartialTransaction{Tx: tran}
+	partial.Tx.Inputs = append([]TransactionInput{}, tran.Inputs...)
+	partial.Tx.Outputs = append([]TransactionOutput{}, tran.Outputs...)
+	for i := range partial.Tx.Inputs {
+		partial.Tx.Inputs[i].Script = nil
+		partial.Inputs = append(partial.Inputs, PartialInput{PrevOutput: prevOutputs[i]})
+	}
+	return partial, nil
+}
+
+//NewPartialTransaction Start collecting the signatures of an unsigned transaction spending outputs of the chain or the pool
+func (chain *Blockchain) NewPartialTransaction(tran Transaction) (*PartialTransaction, error) {
+	var prevOutputs []TransactionOutput
+	for i := range tran.Inputs {
+		output, exist := chain.OutputOf(&tran.Inputs[i])
+		if !exist {
+			return nil, fmt.Errorf("Cannot find the output spent by input %d", i)
+		}
+		prevOutputs = append(prevOutputs, *output)
+	}
+	return CreatePartialTransaction(tran, prevOutputs)
+}
+
+//SignersOf Get the keys which may sign an input and how many signatures it needs.
+//A pay to public key hash input returns no key: any key of its address signs it.
+func (partial *PartialTransaction) SignersOf(index int) (int, []util.PublicKey, error) {
+	if index < 0 || index >= len(partial.Inputs) {
+		return 0, nil, fmt.Errorf("Transaction has no input %d", index)
+	}
+	output := &partial.Inputs[index].PrevOutput
+	if len(output.Script) == 0 {
+		return 1, nil, nil
+	}
+	m, pubs, err := ParseMultiSigScript(output.Script)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Input %d spends neither a public key hash nor a multisig output", index)
+	}
+	return m, pubs, nil
+}
+
+//SignInput Add the signature of signer to an input, replacing a previous one of the same key
+func (partial *PartialTransaction) SignInput(index int, signer util.PrivateKey) error {
+	if !partial.canSign(index, signer.Public()) {
+		return fmt.Errorf("Key cannot sign input %d", index)
+	}
+	signature, err := partial.Tx.SignatureOfSpent(index, SigHashAll|SigHashSpentOutput, &partial.Inputs[index].PrevOutput, signer)
+	if err != nil {
+		return err
+	}
+	partial.addSignature(index, PartialSignature{PublicKey: signer.Public(), Signature: signature})
+	return nil
+}
+
+//Sign Add the signature of signer to every input it can sign, return the number of inputs signed
+func (partial *PartialTransaction) Sign(signer util.PrivateKey) (int, error) {
+	signed := 0
+	for i := range partial.Inputs {
+		if !partial.canSign(i, signer.Public()) {
+			continue
+		}
+		if err := partial.SignInput(i, signer); err != nil {
+			return signed, err
+		}
+		signed++
+	}
+	return signed, nil
+}
+
+//Merge Add the signatures collected by another copy of the same partial transaction
+func (partial *PartialTransaction) Merge(other *PartialTransaction) error {
+	if other.Tx.TxID() != partial.Tx.TxID() || len(other.Inputs) != len(partial.Inputs) {
+		return errors.New("Cannot merge partial transactions of different transactions")
+	}
+	for i := range partial.Inputs {
+		if !bytes.Equal(appendOutput(nil, &other.Inputs[i].PrevOutput), appendOutput(nil, &partial.Inputs[i].PrevOutput)) {
+			return fmt.Errorf("Input %d spends a different output in the merged transaction", i)
+		}
+	}
+
+	/* Check everything first, so a bad signature leaves the transaction unchanged */
+	for i := range other.Inputs {
+		for _, signature := range other.Inputs[i].Signatures {
+			if err := partial.verifySignature(i, signature); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range other.Inputs {
+		for _, signature := range other.Inputs[i].Signatures {
+			partial.addSignature(i, signature)
+		}
+	}
+	return nil
+}
+
+//IsComplete Check whether every input has the signatures it needs
+func (partial *PartialTransaction) IsComplete() bool {
+	for i := range partial.Inputs {
+		m, _, err := partial.SignersOf(i)
+		if err != nil || len(partial.Inputs[i].Signatures) < m {
+			return false
+		}
+	}
+	return true
+}
+
+//Finalize Build the unlocking script of every input from the collected signatures, and check they unlock the outputs spent
+func (partial *PartialTransaction) Finalize() error {
+	scripts := make([]Script, len(partial.Inputs))
+	for i := range partial.Inputs {
+		m, pubs, err := partial.SignersOf(i)
+		if err != nil {
+			return err
+		}
+		signatures := partial.Inputs[i].Signatures
+		if len(signatures) < m {
+			return fmt.Errorf("Input %d has %d of the %d signatures it needs", i, len(signatures), m)
+		}
+
+		if pubs == nil {
+			scripts[i] = SignatureScript(signatures[0].Signature, signatures[0].PublicKey)
+			continue
+		}
+		/* A multisig script takes the signatures in the order of the keys */
+		var ordered [][]byte
+		for _, pub := range pubs {
+			for _, signature := range signatures {
+				if len(ordered) < m && bytes.Equal(signature.PublicKey.Bytes(), pub.Bytes()) {
+					ordered = append(ordered, signature.Signature)
+				}
+			}
+		}
+		scripts[i] = MultiSigSignatureScript(ordered)
+	}
+
+	finalized := partial.Tx
+	finalized.Inputs = append([]TransactionInput{}, partial.Tx.Inputs...)
+	for i := range finalized.Inputs {
+		finalized.Inputs[i].Script = scripts[i]
+	}
+	for i := range finalized.Inputs {
+		locking := partial.Inputs[i].PrevOutput.LockingScript()
+		if err := executeScripts(scripts[i], locking, &scriptContext{tran: &finalized, inputIndex: i, spent: &partial.Inputs[i].PrevOutput}); err != nil {
+			return fmt.Errorf("Input %d is not unlocked: %s", i, err)
+		}
+	}
+	partial.Tx = finalized
+	return nil
+}
+
+//Extract Get the signed transaction, ready to be broadcast, once the partial transaction is finalized
+func (partial *PartialTransaction) Extract() (*Transaction, error) {
+	for i := range partial.Tx.Inputs {
+		if len(partial.Tx.Inputs[i].Script) == 0 {
+			return nil, errors.New("Partial transaction is not finalized")
+		}
+	}
+	tran := partial.Tx
+	tran.Inputs = append([]TransactionInput{}, partial.Tx.Inputs...)
+	tran.Outputs = append([]TransactionOutput{}, partial.Tx.Outputs...)
+	return &tran, nil
+}
+
+//Encode Serialize the partial transaction to pass it to the other signers
+func (partial *PartialTransaction) Encode() ([]byte, error) {
+	file := partialFile{
+		Version:        partialVersion,
+		ID:             partial.Tx.ID,
+		LockTime:       partial.Tx.LockTime,
+		IssuanceName:   partial.Tx.Issuance.Name,
+		IssuanceAmount: partial.Tx.Issuance.Amount,
+		Sender:         partial.Tx.Sender.String(),
+	}
+	for i, input := range partial.Tx.Inputs {
+		entry := partialFileInput{
+			PrevTxID:    append([]byte{}, input.PrevtxMap[:]...),
+			OutputIndex: input.OutputIndex,
+			Sequence:    input.Sequence,
+			Script:      input.Script,
+			PrevOutput:  encodePartialOutput(&partial.Inputs[i].PrevOutput),
+		}
+		for _, signature := range partial.Inputs[i].Signatures {
+			entry.Signatures = append(entry.Signatures, partialFileSignature{
+				PublicKey: signature.PublicKey.Bytes(),
+				Signature: signature.Signature,
+			})
+		}
+		file.Inputs = append(file.Inputs, entry)
+	}
+	for i := range partial.Tx.Outputs {
+		file.Outputs = append(file.Outputs, encodePartialOutput(&partial.Tx.Outputs[i]))
+	}
+	return json.MarshalIndent(&file, "", "  ")
+}
+
+//DecodePartialTransaction Parse a partial transaction serialized by Encode, its signatures are checked
+func DecodePartialTransaction(data []byte) (*PartialTransaction, error) {
+	var file partialFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Invalid partial transaction: %s", err)
+	}
+	if file.Version != partialVersion {
+		return nil, fmt.Errorf("Unsupported partial transaction version %d", file.Version)
+	}
+
+	partial := &PartialTransaction{}
+	partial.Tx.ID = file.ID
+	partial.Tx.LockTime = file.LockTime
+	partial.Tx.Issuance = AssetIssuance{Name: file.IssuanceName, Amount: file.IssuanceAmount}
+	sender, err := util.ParseAddress(file.Sender)
+	if err != nil {
+		return nil, err
+	}
+	partial.Tx.Sender = sender
+
+	for i, entry := range file.Outputs {
+		output, err := decodePartialOutput(entry)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid output %d: %s", i, err)
+		}
+		partial.Tx.Outputs = append(partial.Tx.Outputs, output)
+	}
+	for i, entry := range file.Inputs {
+		if len(entry.PrevTxID) != config.HashSize {
+			return nil, fmt.Errorf("Invalid input %d: wrong previous transaction id length", i)
+		}
+		input := TransactionInput{OutputIndex: entry.OutputIndex, Sequence: entry.Sequence, Script: entry.Script}
+		copy(input.PrevtxMap[:], entry.PrevTxID)
+		prevOutput, err := decodePartialOutput(entry.PrevOutput)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid output spent by input %d: %s", i, err)
+		}
+		partial.Tx.Inputs = append(partial.Tx.Inputs, input)
+		partial.Inputs = append(partial.Inputs, PartialInput{PrevOutput: prevOutput})
+	}
+	if len(partial.Inputs) == 0 {
+		return nil, errors.New("Transaction has no input")
+	}
+
+	/* The inputs are all known before checking the signatures, which commit to all of them */
+	for i, entry := range file.Inputs {
+		for _, fileSignature := range entry.Signatures {
+			pub, err := util.ParsePublicKey(fileSignature.PublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid signature of input %d: %s", i, err)
+			}
+			signature := PartialSignature{PublicKey: pub, Signature: fileSignature.Signature}
+			if err := partial.verifySignature(i, signature); err != nil {
+				return nil, err
+			}
+			partial.addSignature(i, signature)
+		}
+	}
+	return partial, nil
+}
+
+/*
+ * Check whether a key is one of the signers of an input
+ */
+func (partial *PartialTransaction) canSign(index int, pub util.PublicKey) bool {
+	_, pubs, err := partial.SignersOf(index)
+	if err != nil {
+		return false
+	}
+	if pubs == nil {
+		return util.HashPublicKey(pub) == partial.Inputs[index].PrevOutput.Address.Hash()
+	}
+	for _, signer := range pubs {
+		if bytes.Equal(signer.Bytes(), pub.Bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (partial *PartialTransaction) verifySignature(index int, signature PartialSignature) error {
+	if !partial.canSign(index, signature.PublicKey) || !partial.Tx.VerifyInputSignature(index, &partial.Inputs[index].PrevOutput, signature.Signature, signature.PublicKey) {
+		return fmt.Errorf("Invalid signature of input %d", index)
+	}
+	return nil
+}
+
+/*
+ * Add a signature of an input, replacing the one of the same key
+ */
+func (partial *PartialTransaction) addSignature(index int, signature PartialSignature) {
+	input := &partial.Inputs[index]
+	for i := range input.Signatures {
+		if bytes.Equal(input.Signatures[i].PublicKey.Bytes(), signature.PublicKey.Bytes()) {
+			input.Signatures[i] = signature
+			return
+		}
+	}
+	input.Signatures = append(input.Signatures, signature)
+}
+
+func encodePartialOutput(output *TransactionOutput) partialFileOutput {
+	entry := partialFileOutput{Value: output.Value, Address: output.Address.String(), Script: output.Script}
+	if !output.Asset.IsNative() {
+		entry.Asset = append([]byte{}, output.Asset[:]...)
+	}
+	return entry
+}
+
+func decodePartialOutput(entry partialFileOutput) (TransactionOutput, error) {
+	address, err := util.ParseAddress(entry.Address)
+	if err != nil {
+		return TransactionOutput{}, err
+	}
+	output := TransactionOutput{Value: entry.Value, Address: address, Script: entry.Script}
+	if len(entry.Asset) > 0 {
+		if len(entry.Asset) != len(output.Asset) {
+			return TransactionOutput{}, errors.New("wrong asset id length")
+		}
+		copy(output.Asset[:], entry.Asset)
+	}
+	return output, nil
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"../util"
+)
+
+//Payment builds a transaction paying several recipients from the coins of pay to public key hash addresses.
+//The fee is computed from the size of the signed transaction at FeeRate per byte.
+type Payment struct {
+	chain   *Blockchain
+	signers []util.PublicKey
+	outputs []TransactionOutput
+
+	FeeRate       uint64        /* fee per byte of the signed transaction */
+	Selector      CoinSelector  /* BranchAndBound if nil */
+	ChangeAddress ChangeAddress /* the change goes back to the first payer if nil */
+}
+
+//NewPayment Start a payment from the address of the public key signer, which will sign every input
+func (chain *Blockchain) NewPayment(signer util.PublicKey, feeRate uint64) *Payment {
+	return chain.NewPaymentFrom([]util.PublicKey{signer}, feeRate)
+}
+
+//NewPaymentFrom Start a payment from the coins of the addresses of several public keys, e.g. all the keys of a wallet.
+//Each input is signed by the key of the address it spends.
+func (chain *Blockchain) NewPaymentFrom(signers []util.PublicKey, feeRate uint64) *Payment {
+	return &Payment{chain: chain, signers: signers, FeeRate: feeRate}
+}
+
+//From Get the address of the first payer, which sends the payment
+func (payment *Payment) From() util.Address {
+	return util.NewAddress(payment.chain.params.PubKeyHashAddrID, payment.signers[0])
+}
+
+//Froms Get the addresses the payment spends the coins of
+func (payment *Payment) Froms() []util.Address {
+	var froms []util.Address
+	for _, signer := range payment.signers {
+		froms = append(froms, util.NewAddress(payment.chain.params.PubKeyHashAddrID, signer))
+	}
+	return froms
+}
+
+//AddOutput Pay amount to an address
+func (payment *Payment) AddOutput(to util.Address, amount uint64) *Payment {
+	payment.outputs = append(payment.outputs, TransactionOutput{Value: amount, Address: to})
+	return payment
+}
+
+//Build Select the coins paying the outputs and the fee, and make the unsigned transaction.
+//The change goes to the Address given by ChangeAddress, back to the first payer if it is nil, unless it is dust,
+//i.e. spending it would cost more than it's worth.
+//Return the selection of the coins, which tells the fee and the change.
+func (payment *Payment) Build() (*Transaction, *CoinSelection, error) {
+	if len(payment.signers) == 0 {
+		return nil, nil, errors.New("Payment has no payer")
+	}
+	if len(payment.outputs) == 0 {
+		return nil, nil, errors.New("Payment has no output")
+	}
+	froms := payment.Froms()
+
+	/* The signatures are not made yet, assume they are the longest the keys can make, followed by their hash type */
+	var inputSize int
+	for _, signer := range payment.signers {
+		signatureSize, err := util.MaxSignatureSize(signer)
+		if err != nil {
+			return nil, nil, err
+		}
+		input := TransactionInput{Script: SignatureScript(make([]byte, signatureSize+1), signer)}
+		if size := len(appendInput(nil, &input, true)); size > inputSize {
+			inputSize = size
+		}
+	}
+	changeSize := len(appendOutput(nil, &TransactionOutput{Address: froms[0]}))
+	dust := uint64(inputSize) * payment.FeeRate
+
+	tx := CreateTransaction(0, 0)
+	tx.Outputs = append(tx.Outputs, payment.outputs...)
+	target := CoinTarget{
+		Fee:         uint64(tx.Size()) * payment.FeeRate,
+		FeePerInput: uint64(inputSize) * payment.FeeRate,
+		ChangeFee:   uint64(changeSize) * payment.FeeRate,
+		MinChange:   dust,
+	}
+	for i, output := range payment.outputs {
+		if output.Value == 0 || output.Value < dust {
+			return nil, nil, fmt.Errorf("Output %d of %d is dust", i, output.Value)
+		}
+		target.Amount += output.Value
+	}
+
+	selector := payment.Selector
+	if selector == nil {
+		selector = BranchAndBound{}
+	}
+	selection, err := selector.SelectCoins(payment.chain.spendableCoins(froms, NativeAsset), target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("user %s has no enough spendable balance: %s", describeAddresses(froms), err)
+	}
+
+	for _, coin := range selection.Coins {
+		tx.Inputs = append(tx.Inputs, TransactionInput{PrevtxMap: coin.UTXO.txMap, OutputIndex: coin.UTXO.outputIndex})
+	}
+	if selection.Change > 0 {
+		/* As for a transfer, the change address is only asked for once there is change */
+		changeTo := froms[0]
+		if payment.ChangeAddress != nil {
+			if changeTo, err = payment.ChangeAddress(); err != nil {
+				return nil, nil, err
+			}
+		}
+		tx.Outputs = append(tx.Outputs, TransactionOutput{Value: selection.Change, Address: changeTo})
+	}
+	tx.Sender = froms[0]
+	return &tx, selection, nil
+}
@@ -0,0 +1,365 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"../config"
+	"../util"
+)
+
+//Script is a program of the small stack based language locking and unlocking outputs.
+//An output carries a locking script, the input spending it carries an unlocking script
+//which is run first, the locking script then runs on the resulting stack.
+type Script []byte
+
+//Opcodes of the script language, opcodes from 0x01 to 0x4b push that many bytes
+const (
+	Op0         byte = 0x00 /* push an empty element (false) */
+	OpPushData1 byte = 0x4c /* the next byte is the size of the element to push */
+	OpPushData2 byte = 0x4d /* the next 2 bytes (little endian) are the size of the element to push */
+	Op1         byte = 0x51 /* Op1 to Op16 push the number 1 to 16 */
+	Op16        byte = 0x60
+
+	OpNop    byte = 0x61
+	OpIf     byte = 0x63
+	OpNotIf  byte = 0x64
+	OpElse   byte = 0x67
+	OpEndIf  byte = 0x68
+	OpVerify byte = 0x69
+	OpReturn byte = 0x6a
+
+	OpDrop byte = 0x75
+	OpDup  byte = 0x76
+	OpSwap byte = 0x7c
+	OpSize byte = 0x82
+
+	OpEqual       byte = 0x87
+	OpEqualVerify byte = 0x88
+
+	OpSha256         byte = 0xa8
+	OpHash20         byte = 0xa9 /* truncated SHA-256, the hash addresses commit to */
+	OpCheckSig       byte = 0xac
+	OpCheckSigVerify byte = 0xad
+
+	OpCheckMultiSig       byte = 0xae /* M signatures for M of the N keys, in the order of the keys */
+	OpCheckMultiSigVerify byte = 0xaf
+
+	OpCheckLockTimeVerify byte = 0xb1 /* the lock time of the transaction reaches the top of the stack */
+	OpCheckSequenceVerify byte = 0xb2 /* the relative lock time of the input reaches the top of the stack */
+)
+
+var opcodeNames = map[byte]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSha256:              "OP_SHA256",
+	OpHash20:              "OP_HASH20",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+	OpCheckSequenceVerify: "OP_CHECKSEQUENCEVERIFY",
+}
+
+/*
+ * A parsed instruction of a script, data is only set for pushes
+ */
+type scriptOp struct {
+	opcode byte
+	data   []byte
+}
+
+func (op scriptOp) isPush() bool {
+	return op.opcode <= OpPushData2 || (op.opcode >= Op1 && op.opcode <= Op16)
+}
+
+/*
+ * Split a script into instructions.
+ * Op1 to Op16 are returned with their number as data so the interpreter can push them directly.
+ */
+func (script Script) parse() ([]scriptOp, error) {
+	if len(script) > config.MaxScriptSize {
+		return nil, fmt.Errorf("Script exceeds %d bytes", config.MaxScriptSize)
+	}
+
+	var ops []scriptOp
+	for pc := 0; pc < len(script); {
+		opcode := script[pc]
+		pc++
+
+		var size int
+		switch {
+		case opcode > Op0 && opcode < OpPushData1:
+			size = int(opcode)
+		case opcode == OpPushData1:
+			if pc+1 > len(script) {
+				return nil, errors.New("Script ends in the middle of OP_PUSHDATA1")
+			}
+			size = int(script[pc])
+			pc++
+		case opcode == OpPushData2:
+			if pc+2 > len(script) {
+				return nil, errors.New("Script ends in the middle of OP_PUSHDATA2")
+			}
+			size = int(binary.LittleEndian.Uint16(script[pc:]))
+			pc += 2
+		case opcode >= Op1 && opcode <= Op16:
+			ops = append(ops, scriptOp{opcode, []byte{opcode - Op1 + 1}})
+			continue
+		default:
+			ops = append(ops, scriptOp{opcode, nil})
+			continue
+		}
+
+		if pc+size > len(script) {
+			return nil, errors.New("Script pushes more bytes than it contains")
+		}
+		ops = append(ops, scriptOp{opcode, script[pc : pc+size]})
+		pc += size
+	}
+	return ops, nil
+}
+
+//IsPushOnly Check whether the script only pushes data, as unlocking scripts must
+func (script Script) IsPushOnly() bool {
+	ops, err := script.parse()
+	if err != nil {
+		return false
+	}
+	for _, op := range ops {
+		if !op.isPush() {
+			return false
+		}
+	}
+	return true
+}
+
+//String Disassemble the script
+func (script Script) String() string {
+	ops, err := script.parse()
+	if err != nil {
+		return fmt.Sprintf("[invalid script %x]", []byte(script))
+	}
+
+	var buffer bytes.Buffer
+	for i, op := range ops {
+		if i > 0 {
+			buffer.WriteString(" ")
+		}
+		switch name, known := opcodeNames[op.opcode]; {
+		case op.opcode >= Op1 && op.opcode <= Op16:
+			buffer.WriteString(fmt.Sprintf("OP_%d", op.data[0]))
+		case op.isPush() && op.opcode != Op0:
+			buffer.WriteString(hex.EncodeToString(op.data))
+		case known:
+			buffer.WriteString(name)
+		default:
+			buffer.WriteString(fmt.Sprintf("OP_UNKNOWN_%x", op.opcode))
+		}
+	}
+	return buffer.String()
+}
+
+//ScriptBuilder builds a script with minimal pushes
+type ScriptBuilder struct {
+	script Script
+}
+
+//NewScriptBuilder Create an empty script builder
+func NewScriptBuilder() *ScriptBuilder {
+	return &ScriptBuilder{}
+}
+
+//AddOp Append an opcode
+func (builder *ScriptBuilder) AddOp(opcode byte) *ScriptBuilder {
+	builder.script = append(builder.script, opcode)
+	return builder
+}
+
+//AddData Append the push of a data element
+func (builder *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	switch {
+	case len(data) == 0:
+		builder.script = append(builder.script, Op0)
+	case len(data) < int(OpPushData1):
+		builder.script = append(builder.script, byte(len(data)))
+	case len(data) <= 0xff:
+		builder.script = append(builder.script, OpPushData1, byte(len(data)))
+	default:
+		builder.script = append(builder.script, OpPushData2, byte(len(data)), byte(len(data)>>8))
+	}
+	builder.script = append(builder.script, data...)
+	return builder
+}
+
+//AddInt Append the push of a number
+func (builder *ScriptBuilder) AddInt(n uint64) *ScriptBuilder {
+	if n == 0 {
+		return builder.AddOp(Op0)
+	}
+	if n <= 16 {
+		return builder.AddOp(Op1 + byte(n-1))
+	}
+	return builder.AddData(encodeScriptNumber(n))
+}
+
+//Script Get the built script
+func (builder *ScriptBuilder) Script() Script {
+	return builder.script
+}
+
+/*
+ * Numbers in scripts are unsigned little endian integers of at most 8 bytes, without trailing zeros
+ */
+func encodeScriptNumber(n uint64) []byte {
+	var data []byte
+	for ; n > 0; n >>= 8 {
+		data = append(data, byte(n))
+	}
+	return data
+}
+
+func decodeScriptNumber(data []byte) (uint64, error) {
+	if len(data) > 8 {
+		return 0, errors.New("Script number exceeds 8 bytes")
+	}
+	var n uint64
+	for i := len(data) - 1; i >= 0; i-- {
+		n = n<<8 | uint64(data[i])
+	}
+	return n, nil
+}
+
+//PayToPubKeyHashScript Lock an output to the owner of the public key an address commits to
+func PayToPubKeyHashScript(pubKeyHash [util.AddressHashSize]byte) Script {
+	return NewScriptBuilder().
+		AddOp(OpDup).AddOp(OpHash20).AddData(pubKeyHash[:]).AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+//HashLockScript Lock an output to the owner of a public key who also reveals the preimage of secretHash (SHA-256).
+//Spend it with SignatureScript followed by the push of the preimage.
+func HashLockScript(secretHash [config.HashSize]byte, pubKeyHash [util.AddressHashSize]byte) Script {
+	return NewScriptBuilder().
+		AddOp(OpSha256).AddData(secretHash[:]).AddOp(OpEqualVerify).
+		AddOp(OpDup).AddOp(OpHash20).AddData(pubKeyHash[:]).AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+//TimeLockScript Lock an output to the owner of a public key until lockTime (a height, or a timestamp in ms
+//from config.LockTimeThreshold). Spend it with SignatureScript in a transaction whose LockTime reaches lockTime.
+func TimeLockScript(lockTime uint64, pubKeyHash [util.AddressHashSize]byte) Script {
+	return NewScriptBuilder().
+		AddInt(lockTime).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpHash20).AddData(pubKeyHash[:]).AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+//RelativeTimeLockScript Lock an output to the owner of a public key for sequence blocks (or seconds with
+//config.SequenceLockTimeIsTime) after it is confirmed. Spend it with SignatureScript in an input whose Sequence reaches sequence.
+func RelativeTimeLockScript(sequence uint32, pubKeyHash [util.AddressHashSize]byte) Script {
+	return NewScriptBuilder().
+		AddInt(uint64(sequence)).AddOp(OpCheckSequenceVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpHash20).AddData(pubKeyHash[:]).AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+//NullDataScript Build the locking script of a null-data output, which carries data and can never be spent
+func NullDataScript(data []byte) Script {
+	return NewScriptBuilder().AddOp(OpReturn).AddData(data).Script()
+}
+
+//NullData Get the data carried by a script built by NullDataScript
+func (script Script) NullData() ([]byte, bool) {
+	ops, err := script.parse()
+	if err != nil || len(ops) == 0 || len(ops) > 2 || ops[0].opcode != OpReturn {
+		return nil, false
+	}
+	if len(ops) == 1 {
+		return []byte{}, true
+	}
+	if !ops[1].isPush() {
+		return nil, false
+	}
+	return ops[1].data, true
+}
+
+//MultiSigScript Lock an output to any m of the public keys.
+//Spend it with MultiSigSignatureScript.
+func MultiSigScript(m int, pubs []util.PublicKey) (Script, error) {
+	if len(pubs) == 0 || len(pubs) > config.MaxMultiSigKeys {
+		return nil, fmt.Errorf("A multisig script needs 1 to %d keys", config.MaxMultiSigKeys)
+	}
+	if m <= 0 || m > len(pubs) {
+		return nil, fmt.Errorf("Cannot require %d signatures of %d keys", m, len(pubs))
+	}
+
+	builder := NewScriptBuilder().AddInt(uint64(m))
+	for _, pub := range pubs {
+		builder.AddData(pub.Bytes())
+	}
+	return builder.AddInt(uint64(len(pubs))).AddOp(OpCheckMultiSig).Script(), nil
+}
+
+//ParseMultiSigScript Get the threshold and the public keys of a script built by MultiSigScript
+func ParseMultiSigScript(script Script) (int, []util.PublicKey, error) {
+	ops, err := script.parse()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(ops) < 4 || ops[len(ops)-1].opcode != OpCheckMultiSig {
+		return 0, nil, errors.New("Not a multisig script")
+	}
+
+	m, errM := decodeScriptNumber(ops[0].data)
+	n, errN := decodeScriptNumber(ops[len(ops)-2].data)
+	if !ops[0].isPush() || errM != nil || errN != nil || int(n) != len(ops)-3 || m == 0 || m > n {
+		return 0, nil, errors.New("Not a multisig script")
+	}
+
+	var pubs []util.PublicKey
+	for _, op := range ops[1 : len(ops)-2] {
+		pub, err := util.ParsePublicKey(op.data)
+		if !op.isPush() || err != nil {
+			return 0, nil, errors.New("Not a multisig script")
+		}
+		pubs = append(pubs, pub)
+	}
+	return int(m), pubs, nil
+}
+
+//MultiSigSignatureScript Build the unlocking script of a multisig output, signatures must follow the order of the keys
+func MultiSigSignatureScript(signatures [][]byte) Script {
+	builder := NewScriptBuilder()
+	for _, signature := range signatures {
+		builder.AddData(signature)
+	}
+	return builder.Script()
+}
+
+//SignatureScript Build the unlocking script of a pay to public key hash output
+func SignatureScript(signature []byte, pub util.PublicKey) Script {
+	return NewScriptBuilder().AddData(signature).AddData(pub.Bytes()).Script()
+}
@@ -0,0 +1,418 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"../config"
+	"../util"
+)
+
+/*
+ * What a script can observe about the input being verified.
+ * Scripts don't see the block, time locks compare to the lock times of the transaction
+ * which are enforced against the block by the chain.
+ */
+type scriptContext struct {
+	tran       *Transaction
+	inputIndex int
+	spent      *TransactionOutput /* output spent by the input, nil if unknown */
+}
+
+/*
+ * The interpreter is deterministic: its result only depends on the scripts and the context.
+ * The cost of a run is bounded by config.MaxScriptCost, the memory by the stack and element limits.
+ */
+type scriptEngine struct {
+	ctx   *scriptContext
+	stack [][]byte
+	exec  []bool /* one entry per open IF, false when its branch is skipped */
+	cost  int
+}
+
+/*
+ * Run the unlocking script of an input then the locking script of the output it spends.
+ * The spend is valid if the top of the stack is true at the end.
+ */
+func executeScripts(unlocking Script, locking Script, ctx *scriptContext) error {
+	if !unlocking.IsPushOnly() {
+		return errors.New("Unlocking script must only push data")
+	}
+
+	engine := scriptEngine{ctx: ctx}
+	for _, script := range []Script{unlocking, locking} {
+		if err := engine.run(script); err != nil {
+			return err
+		}
+	}
+
+	if len(engine.stack) == 0 || !asBool(engine.stack[len(engine.stack)-1]) {
+		return errors.New("Script evaluated to false")
+	}
+	return nil
+}
+
+func asBool(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func fromBool(value bool) []byte {
+	if value {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+func (engine *scriptEngine) push(data []byte) error {
+	if len(data) > config.MaxScriptElementSize {
+		return fmt.Errorf("Script element exceeds %d bytes", config.MaxScriptElementSize)
+	}
+	if len(engine.stack) >= config.MaxScriptStackSize {
+		return fmt.Errorf("Script stack exceeds %d elements", config.MaxScriptStackSize)
+	}
+	engine.stack = append(engine.stack, data)
+	return nil
+}
+
+func (engine *scriptEngine) pop() ([]byte, error) {
+	if len(engine.stack) == 0 {
+		return nil, errors.New("Script pops an empty stack")
+	}
+	data := engine.stack[len(engine.stack)-1]
+	engine.stack = engine.stack[:len(engine.stack)-1]
+	return data, nil
+}
+
+func (engine *scriptEngine) peek() ([]byte, error) {
+	if len(engine.stack) == 0 {
+		return nil, errors.New("Script reads an empty stack")
+	}
+	return engine.stack[len(engine.stack)-1], nil
+}
+
+func (engine *scriptEngine) addCost(cost int) error {
+	engine.cost += cost
+	if engine.cost > config.MaxScriptCost {
+		return fmt.Errorf("Script exceeds the cost limit %d", config.MaxScriptCost)
+	}
+	return nil
+}
+
+func (engine *scriptEngine) executing() bool {
+	for _, branch := range engine.exec {
+		if !branch {
+			return false
+		}
+	}
+	return true
+}
+
+func (engine *scriptEngine) run(script Script) error {
+	ops, err := script.parse()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if !op.isPush() {
+			if err := engine.addCost(1); err != nil {
+				return err
+			}
+		}
+
+		/* Skipped branches still have to track nested conditionals */
+		if !engine.executing() && (op.opcode < OpIf || op.opcode > OpEndIf) {
+			continue
+		}
+
+		if op.isPush() {
+			if err := engine.push(op.data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := engine.step(op.opcode); err != nil {
+			return err
+		}
+	}
+
+	if len(engine.exec) != 0 {
+		return errors.New("Script has an unterminated OP_IF")
+	}
+	return nil
+}
+
+func (engine *scriptEngine) step(opcode byte) error {
+	switch opcode {
+	case OpNop:
+		return nil
+
+	case OpIf, OpNotIf:
+		branch := false
+		if engine.executing() {
+			condition, err := engine.pop()
+			if err != nil {
+				return err
+			}
+			branch = asBool(condition) == (opcode == OpIf)
+		}
+		engine.exec = append(engine.exec, branch)
+		return nil
+
+	case OpElse:
+		if len(engine.exec) == 0 {
+			return errors.New("Script has OP_ELSE without OP_IF")
+		}
+		engine.exec[len(engine.exec)-1] = !engine.exec[len(engine.exec)-1]
+		return nil
+
+	case OpEndIf:
+		if len(engine.exec) == 0 {
+			return errors.New("Script has OP_ENDIF without OP_IF")
+		}
+		engine.exec = engine.exec[:len(engine.exec)-1]
+		return nil
+
+	case OpVerify:
+		return engine.verify()
+
+	case OpReturn:
+		return errors.New("Script executed OP_RETURN")
+
+	case OpDrop:
+		_, err := engine.pop()
+		return err
+
+	case OpDup:
+		top, err := engine.peek()
+		if err != nil {
+			return err
+		}
+		return engine.push(top)
+
+	case OpSwap:
+		if len(engine.stack) < 2 {
+			return errors.New("Script swaps less than 2 elements")
+		}
+		n := len(engine.stack)
+		engine.stack[n-1], engine.stack[n-2] = engine.stack[n-2], engine.stack[n-1]
+		return nil
+
+	case OpSize:
+		top, err := engine.peek()
+		if err != nil {
+			return err
+		}
+		return engine.push(encodeScriptNumber(uint64(len(top))))
+
+	case OpEqual, OpEqualVerify:
+		a, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		b, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		if err := engine.push(fromBool(bytes.Equal(a, b))); err != nil {
+			return err
+		}
+		if opcode == OpEqualVerify {
+			return engine.verify()
+		}
+		return nil
+
+	case OpSha256:
+		top, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(top)
+		return engine.push(hash[:])
+
+	case OpHash20:
+		top, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		hash := util.Hash20(top)
+		return engine.push(hash[:])
+
+	case OpCheckSig, OpCheckSigVerify:
+		if err := engine.addCost(config.ScriptSigCheckCost); err != nil {
+			return err
+		}
+		pubData, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		if err := engine.push(fromBool(engine.checkSignature(signature, pubData))); err != nil {
+			return err
+		}
+		if opcode == OpCheckSigVerify {
+			return engine.verify()
+		}
+		return nil
+
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		valid, err := engine.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		if err := engine.push(fromBool(valid)); err != nil {
+			return err
+		}
+		if opcode == OpCheckMultiSigVerify {
+			return engine.verify()
+		}
+		return nil
+
+	case OpCheckLockTimeVerify:
+		top, err := engine.peek()
+		if err != nil {
+			return err
+		}
+		lockTime, err := decodeScriptNumber(top)
+		if err != nil {
+			return err
+		}
+		return engine.checkLockTime(lockTime)
+
+	case OpCheckSequenceVerify:
+		top, err := engine.peek()
+		if err != nil {
+			return err
+		}
+		sequence, err := decodeScriptNumber(top)
+		if err != nil {
+			return err
+		}
+		return engine.checkSequence(sequence)
+	}
+
+	return fmt.Errorf("Script has an unknown opcode 0x%x", opcode)
+}
+
+func (engine *scriptEngine) verify() error {
+	top, err := engine.pop()
+	if err != nil {
+		return err
+	}
+	if !asBool(top) {
+		return errors.New("Script verification failed")
+	}
+	return nil
+}
+
+/*
+ * A malformed key or signature makes the check fail instead of aborting the script,
+ * so scripts can branch on the result.
+ */
+func (engine *scriptEngine) checkSignature(signature []byte, pubData []byte) bool {
+	pub, err := util.ParsePublicKey(pubData)
+	if err != nil {
+		return false
+	}
+	return engine.ctx.tran.verifyInputSignature(engine.ctx.inputIndex, engine.ctx.spent, signature, pub)
+}
+
+func (engine *scriptEngine) popNumber() (uint64, error) {
+	data, err := engine.pop()
+	if err != nil {
+		return 0, err
+	}
+	return decodeScriptNumber(data)
+}
+
+/*
+ * Pop <sig 1> ... <sig m> <m> <key 1> ... <key n> <n> and check every signature matches one of the keys.
+ * The signatures must be in the order of the keys, so each key is tried at most once.
+ */
+func (engine *scriptEngine) checkMultiSig() (bool, error) {
+	n, err := engine.popNumber()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 || n > config.MaxMultiSigKeys {
+		return false, fmt.Errorf("Multisig needs 1 to %d keys", config.MaxMultiSigKeys)
+	}
+	if err := engine.addCost(int(n) * config.ScriptSigCheckCost); err != nil {
+		return false, err
+	}
+
+	pubs := make([][]byte, n)
+	for i := int(n) - 1; i >= 0; i-- {
+		if pubs[i], err = engine.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	m, err := engine.popNumber()
+	if err != nil {
+		return false, err
+	}
+	if m == 0 || m > n {
+		return false, fmt.Errorf("Multisig cannot require %d signatures of %d keys", m, n)
+	}
+	signatures := make([][]byte, m)
+	for i := int(m) - 1; i >= 0; i-- {
+		if signatures[i], err = engine.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	key := 0
+	for _, signature := range signatures {
+		for key < len(pubs) && !engine.checkSignature(signature, pubs[key]) {
+			key++
+		}
+		if key == len(pubs) {
+			return false, nil
+		}
+		key++
+	}
+	return true, nil
+}
+
+/*
+ * The lock time of the transaction must be of the same kind (height or timestamp) and reach lockTime
+ */
+func (engine *scriptEngine) checkLockTime(lockTime uint64) error {
+	txLockTime := engine.ctx.tran.LockTime
+	if (lockTime < config.LockTimeThreshold) != (txLockTime < config.LockTimeThreshold) {
+		return errors.New("Lock time of the transaction and the script are of different kinds")
+	}
+	if txLockTime < lockTime {
+		return fmt.Errorf("Output is locked until %d", lockTime)
+	}
+	return nil
+}
+
+/*
+ * The relative lock time of the input must be of the same kind (blocks or seconds) and reach sequence
+ */
+func (engine *scriptEngine) checkSequence(sequence uint64) error {
+	if sequence > 0xffffffff {
+		return errors.New("Relative lock time exceeds 4 bytes")
+	}
+	txSequence := engine.ctx.tran.Inputs[engine.ctx.inputIndex].Sequence
+	if (sequence&config.SequenceLockTimeIsTime != 0) != (txSequence&config.SequenceLockTimeIsTime != 0) {
+		return errors.New("Relative lock time of the input and the script are of different kinds")
+	}
+	if txSequence&config.SequenceLockTimeMask < uint32(sequence)&config.SequenceLockTimeMask {
+		return fmt.Errorf("Output is locked for %d after its confirmation", sequence&config.SequenceLockTimeMask)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"fmt"
+
+	"../util"
+)
+
+//SigHashType selects the parts of a transaction a signature commits to, it is appended to the signature
+type SigHashType byte
+
+//Signature hash types, SigHashSpentOutput and SigHashAnyoneCanPay can be combined with the others
+const (
+	SigHashAll          SigHashType = 0x01 /* all Inputs and Outputs */
+	SigHashNone         SigHashType = 0x02 /* all Inputs and no output, anyone can decide where the coins go */
+	SigHashSingle       SigHashType = 0x03 /* all Inputs and the output of the same index as the signed input */
+	SigHashSpentOutput  SigHashType = 0x40 /* also the value, asset and locking script of the output spent by the input */
+	SigHashAnyoneCanPay SigHashType = 0x80 /* only the signed input, anyone can add Inputs */
+)
+
+/*
+ * Get the message signed for an input.
+ * It always commits to the index of the input, so a signature can't be copied to another input,
+ * and to the hash type, so it can't be changed to one committing to less.
+ * With SigHashSpentOutput it commits to spent, the output spent by the input, so a signer who doesn't
+ * have the chain can't be misled about the value it spends: the signature is invalid for any other output.
+ * The unlocking scripts are never signed.
+ */
+func (tran *Transaction) sigHashData(index int, hashType SigHashType, spent *TransactionOutput) ([]byte, error) {
+	if index < 0 || index >= len(tran.Inputs) {
+		return nil, fmt.Errorf("Transaction has no input %d", index)
+	}
+	if hashType&SigHashSpentOutput != 0 && spent == nil {
+		return nil, fmt.Errorf("Signature of input %d commits to the output it spends, which is unknown", index)
+	}
+	base := hashType &^ (SigHashAnyoneCanPay | SigHashSpentOutput)
+	if base < SigHashAll || base > SigHashSingle {
+		return nil, fmt.Errorf("Unknown signature hash type 0x%x", byte(hashType))
+	}
+	if base == SigHashSingle && index >= len(tran.Outputs) {
+		return nil, fmt.Errorf("No output matches input %d signed with SigHashSingle", index)
+	}
+
+	data := appendUint32(nil, uint32(index))
+	data = append(data, byte(hashType))
+
+	for i, input := range tran.Inputs {
+		if hashType&SigHashAnyoneCanPay != 0 && i != index {
+			continue
+		}
+		data = appendUint32(data, input.OutputIndex)
+		data = append(data, input.PrevtxMap[:]...)
+		/* The others may update their relative lock times if the outputs are not all signed */
+		if base == SigHashAll || i == index {
+			data = appendUint32(data, input.Sequence)
+		}
+	}
+
+	for i, output := range tran.Outputs {
+		if base == SigHashNone || (base == SigHashSingle && i != index) {
+			continue
+		}
+		data = appendUint64(data, output.Value)
+		data = appendAddress(data, output.Address)
+		data = appendScript(data, output.Script)
+		data = append(data, output.Asset[:]...)
+	}
+	data = appendUint64(data, tran.LockTime)
+	data = appendIssuance(data, tran.Issuance)
+
+	if hashType&SigHashSpentOutput != 0 {
+		data = appendUint64(data, spent.Value)
+		data = append(data, spent.Asset[:]...)
+		data = appendScript(data, spent.LockingScript())
+	}
+	return data, nil
+}
+
+//SignatureOf Sign an input of the transaction with a key, to build unlocking scripts of custom locking scripts.
+//The hash type is appended to the signature.
+func (tran *Transaction) SignatureOf(index int, hashType SigHashType, signer util.PrivateKey) ([]byte, error) {
+	return tran.SignatureOfSpent(index, hashType, nil, signer)
+}
+
+//SignatureOfSpent Sign an input of the transaction with a key, knowing spent, the output spent by the input.
+//With SigHashSpentOutput the signature is only valid if the input really spends an output equal to spent.
+func (tran *Transaction) SignatureOfSpent(index int, hashType SigHashType, spent *TransactionOutput, signer util.PrivateKey) ([]byte, error) {
+	data, err := tran.sigHashData(index, hashType, spent)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := util.Sign(data, signer)
+	if err != nil {
+		return nil, err
+	}
+	return append(signature, byte(hashType)), nil
+}
+
+//VerifyInputSignature Check a signature (followed by its hash type) of an input was made by pub,
+//e.g. to check a partial signature of a multisig input before adding the own one.
+//spent is the output spent by the input, a signature with SigHashSpentOutput doesn't verify if it is nil.
+func (tran *Transaction) VerifyInputSignature(index int, spent *TransactionOutput, signature []byte, pub util.PublicKey) bool {
+	return tran.verifyInputSignature(index, spent, signature, pub)
+}
+
+func (tran *Transaction) verifyInputSignature(index int, spent *TransactionOutput, signature []byte, pub util.PublicKey) bool {
+	if len(signature) == 0 {
+		return false
+	}
+	data, err := tran.sigHashData(index, SigHashType(signature[len(signature)-1]), spent)
+	if err != nil {
+		return false
+	}
+	return util.VerifySignature(data, signature[:len(signature)-1], pub) == nil
+}
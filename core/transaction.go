@@ -3,7 +3,7 @@ package core
 import (
 	"bytes"
 	"crypto/md5"
-	"crypto/rsa"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 
@@ -15,23 +15,31 @@ import (
 type TransactionInput struct {
 	PrevtxMap   [config.HashSize]byte
 	OutputIndex uint32
-	Signature   []byte
+	Sequence    uint32 /* relative lock time, 0 if the input can be spent right after the output is confirmed */
+	Script      Script /* unlocking script, satisfies the locking script of the output being spent */
 }
 
 //TransactionOutput contains instructions for sending bitcoins.
+//Outputs without a Script pay to the public key hash of their Address,
+//the Address of the others is the script address of the Script.
+//Value counts native coins, or units of Asset if it is set.
 type TransactionOutput struct {
 	Value   uint64
-	Address rsa.PublicKey
+	Address util.Address
+	Script  Script
+	Asset   AssetID
 }
 
 //Transaction contains a list of Inputs and	 Outputs.
 // To become a valid transation, it must contain the all signatures
 // from all users
 type Transaction struct {
-	ID      string
-	Inputs  []TransactionInput
-	Outputs []TransactionOutput
-	Sender  rsa.PublicKey
+	ID       string
+	Inputs   []TransactionInput
+	Outputs  []TransactionOutput
+	LockTime uint64 /* the transaction is final from this height, or this timestamp in ms (see config.LockTimeThreshold) */
+	Issuance AssetIssuance
+	Sender   util.Address
 }
 
 //CreateTransaction create a transaction with specified count of inputs and outputs
@@ -50,70 +58,139 @@ func CreateTransaction(ninput int, noutput int) Transaction {
 	return tran
 }
 
-/*
- * Get the raw data to sign.
- * Basically it seralizes the transaction except the signature field
- */
-func (tran *Transaction) getRawDataToSign() []byte {
+func (tran *Transaction) getRawData(withWitness bool) []byte {
 	var data []byte
 	for i := 0; i < len(tran.Inputs); i++ {
-		data = appendUint32(data, tran.Inputs[i].OutputIndex)
-		data = append(data, tran.Inputs[i].PrevtxMap[:]...)
+		data = appendInput(data, &tran.Inputs[i], withWitness)
 	}
 
 	for i := 0; i < len(tran.Outputs); i++ {
-		data = appendUint64(data, tran.Outputs[i].Value)
-		data = appendAddress(data, &tran.Outputs[i].Address)
+		data = appendOutput(data, &tran.Outputs[i])
 	}
-	return data
+	data = appendUint64(data, tran.LockTime)
+	return appendIssuance(data, tran.Issuance)
 }
 
-// GetRawDataToHash Get the raw data to hash the whole transaction
+// GetRawDataToHash Get the raw data to hash the transaction into its id.
+// The unlocking scripts (the witness) are excluded, so signing or re-signing a transaction doesn't change its id
+// and Transactions spending its Outputs stay valid.
 func (tran *Transaction) GetRawDataToHash() []byte {
-	var data []byte
-	for i := 0; i < len(tran.Inputs); i++ {
-		data = appendUint32(data, tran.Inputs[i].OutputIndex)
-		data = append(data, tran.Inputs[i].PrevtxMap[:]...)
-		data = append(data, tran.Inputs[i].Signature...)
-	}
+	return tran.getRawData(false)
+}
 
-	for i := 0; i < len(tran.Outputs); i++ {
-		data = appendUint64(data, tran.Outputs[i].Value)
-		data = appendAddress(data, &tran.Outputs[i].Address)
-	}
-	return data
+//GetRawDataToHashWithWitness Get the raw data to hash the whole transaction, including the unlocking scripts
+func (tran *Transaction) GetRawDataToHashWithWitness() []byte {
+	return tran.getRawData(true)
 }
 
-//GetRawDataToHashForTest Get the raw data to hash the whole transaction
+//GetRawDataToHashForTest Get the raw data to hash the transaction into its id
 func (tran *Transaction) GetRawDataToHashForTest() []byte {
 	return tran.GetRawDataToHash()
 }
 
-//SignTransaction Sign a transaction in place (in practice, it should be called by each signer individually)
-func (tran *Transaction) SignTransaction(signers []*rsa.PrivateKey) error {
+//TxID Get the id of the transaction, which Inputs spending its Outputs refer to (PrevtxMap)
+func (tran *Transaction) TxID() [config.HashSize]byte {
+	return sha256.Sum256(tran.GetRawDataToHash())
+}
+
+//WitnessHash Get the hash of the whole transaction, including the unlocking scripts
+func (tran *Transaction) WitnessHash() [config.HashSize]byte {
+	return sha256.Sum256(tran.GetRawDataToHashWithWitness())
+}
+
+//Size Get the size in bytes of the whole transaction, including the unlocking scripts, which fees are charged for
+func (tran *Transaction) Size() int {
+	return len(tran.GetRawDataToHashWithWitness())
+}
+
+//IsFinal Check whether the lock time of the transaction allows it in the block at height with timeStampMs
+func (tran *Transaction) IsFinal(height uint64, timeStampMs uint64) bool {
+	if tran.LockTime < config.LockTimeThreshold {
+		return height >= tran.LockTime
+	}
+	return timeStampMs >= tran.LockTime
+}
+
+//SignTransaction Sign a transaction in place with the keys of all the signers at once.
+//Signers who don't share their keys (e.g. on different or offline machines) sign a PartialTransaction instead.
+//Every input must spend a pay to public key hash output of its signer, the signatures commit to the whole transaction.
+func (tran *Transaction) SignTransaction(signers []util.PrivateKey) error {
 	if len(signers) != len(tran.Inputs) {
 		return errors.New("Number of signers mismatch that of Inputs")
 	}
-	data := tran.getRawDataToSign()
 	for i := 0; i < len(signers); i++ {
-		signature, err := util.Sign(data, signers[i])
+		signature, err := tran.SignatureOf(i, SigHashAll, signers[i])
 		if err != nil {
 			return err
 		}
-		tran.Inputs[i].Signature = signature
+		tran.Inputs[i].Script = SignatureScript(signature, signers[i].Public())
+	}
+	return nil
+}
+
+//SignMultiSigInput Add the signature of signer to an input spending an output locked by the multisig script.
+//Signatures of the other signers are kept in the order of the keys, so the input can be passed around
+//and signed by each of them until it holds the required number of signatures.
+func (tran *Transaction) SignMultiSigInput(index int, script Script, signer util.PrivateKey) error {
+	if index < 0 || index >= len(tran.Inputs) {
+		return fmt.Errorf("Transaction has no input %d", index)
+	}
+	m, pubs, err := ParseMultiSigScript(script)
+	if err != nil {
+		return err
+	}
+
+	signature, err := tran.SignatureOf(index, SigHashAll, signer)
+	if err != nil {
+		return err
+	}
+
+	/* Match the present signatures to their keys */
+	ops, err := tran.Inputs[index].Script.parse()
+	if err != nil {
+		return err
+	}
+	signatures := make([][]byte, len(pubs))
+	for _, op := range ops {
+		for i, pub := range pubs {
+			if signatures[i] == nil && tran.verifyInputSignature(index, nil, op.data, pub) {
+				signatures[i] = op.data
+				break
+			}
+		}
+	}
+
+	signed := false
+	for i, pub := range pubs {
+		if pub == signer.Public() {
+			signatures[i] = signature
+			signed = true
+		}
+	}
+	if !signed {
+		return errors.New("Signer is not a key of the multisig script")
+	}
+
+	var ordered [][]byte
+	for _, signature := range signatures {
+		if signature != nil && len(ordered) < m {
+			ordered = append(ordered, signature)
+		}
 	}
+	tran.Inputs[index].Script = MultiSigSignatureScript(ordered)
 	return nil
 }
 
-//VerifyTransaction Verify whether a transaction has valid signatures.
+//VerifyTransaction Verify whether a transaction has valid signatures, assuming every input spends
+//a pay to public key hash output of the given keys.
 //Note that it doesn't verify whether the transaction is valid in the chain.
-func (tran *Transaction) VerifyTransaction(inputAddresses []*rsa.PublicKey) error {
+func (tran *Transaction) VerifyTransaction(inputAddresses []util.PublicKey) error {
 	if len(inputAddresses) != len(tran.Inputs) {
 		return errors.New("Number of Addresses mismatch that of Inputs")
 	}
-	data := tran.getRawDataToSign()
 	for i := 0; i < len(inputAddresses); i++ {
-		err := util.VerifySignature(data, tran.Inputs[i].Signature, inputAddresses[i])
+		locking := PayToPubKeyHashScript(util.HashPublicKey(inputAddresses[i]))
+		err := executeScripts(tran.Inputs[i].Script, locking, &scriptContext{tran: tran, inputIndex: i})
 		if err != nil {
 			return err
 		}
@@ -121,22 +198,45 @@ func (tran *Transaction) VerifyTransaction(inputAddresses []*rsa.PublicKey) erro
 	return nil
 }
 
+//LockingScript Get the script locking the output
+func (output *TransactionOutput) LockingScript() Script {
+	if len(output.Script) == 0 {
+		return PayToPubKeyHashScript(output.Address.Hash())
+	}
+	return output.Script
+}
+
+//IsNullData Check whether the output only carries data, it is never added to the unspent outputs
+func (output *TransactionOutput) IsNullData() bool {
+	_, ok := output.Script.NullData()
+	return ok
+}
+
+//LockWithScript Lock the output with a script, its Address becomes the script address on the network
+func (output *TransactionOutput) LockWithScript(script Script, params *config.ChainParams) {
+	output.Script = script
+	output.Address = util.NewScriptAddress(params.ScriptHashAddrID, script)
+}
+
 //Print details of transaction input
 func (input TransactionInput) Print() string {
-	return fmt.Sprintf("TransactionInput:%s[PrevtxMap:%s,OutputIndex:%x,Signature:%x],",
+	return fmt.Sprintf("TransactionInput:%s[PrevtxMap:%s,OutputIndex:%x,Sequence:%x,Script:%x],",
 		util.Hash(input),
 		util.HashBytes(input.PrevtxMap),
 		input.OutputIndex,
-		md5.Sum(input.Signature),
+		input.Sequence,
+		md5.Sum(input.Script),
 	)
 }
 
 //Print details of transaction output
 func (output TransactionOutput) Print() string {
-	return fmt.Sprintf("TransactionOutput:%s[Address:%v,Value:%v],",
+	return fmt.Sprintf("TransactionOutput:%s[Address:%v,Value:%v,Script:%s,Asset:%s],",
 		util.Hash(output),
-		util.GetShortIdentity(output.Address),
+		output.Address.String(),
 		output.Value,
+		output.Script.String(),
+		output.Asset,
 	)
 }
 
@@ -151,5 +251,6 @@ func (tran Transaction) Print() string {
 		buffer.WriteString(out.Print())
 	}
 
-	return fmt.Sprintf("Transaction:%s[%s],", tran.ID, buffer.String())
+	return fmt.Sprintf("Transaction:%s[%sLockTime:%d,Issuance:%s/%d],", tran.ID, buffer.String(), tran.LockTime,
+		tran.Issuance.Name, tran.Issuance.Amount)
 }
@@ -2,43 +2,89 @@ package main
 
 import (
 	"bytes"
-	"crypto/rsa"
+	"errors"
+	"flag"
 	"fmt"
 	"math/rand"
+	"os"
 	"time"
 
 	"./config"
 	"./core"
 	"./role"
 	"./util"
+	"./wallet"
 )
 
 var chain core.Blockchain
 var users []*role.User
 var miner *role.Miner
+var clock util.Clock = util.RealClock{}
 
 const userCount = 4
 
-func boostNetwork() {
-	// 1. create the initial user of blockchain
-	firstUser := role.CreateBoostUser()
+var genesisPath = flag.String("genesis", "", "genesis spec (JSON) to boost the blockchain from")
+var keystorePath = flag.String("keystore", "", "keystore of the miner, created if it doesn't exist")
+var passphrase = flag.String("passphrase", "", "passphrase of the keystore")
+var addressIndex = flag.Bool("addrindex", false, "record the history of every address")
+var network = flag.String("network", config.TestNetParams.Name, "network of the keystore, for signmessage")
 
-	// 2. boost the blockchain with initial user
-	diff := core.CreateMADifficulty(10000, 0.2, 16)
-	chain = core.InitializeBlockchainWithDiff(&firstUser.Address, diff)
+/*
+ * Open the keystore of the miner, or create it
+ */
+func loadMinerWallet() (*wallet.Wallet, error) {
+	minerWallet, err := wallet.OpenWallet(*keystorePath, chain.Params())
+	if os.IsNotExist(err) {
+		return wallet.CreateWallet(*keystorePath, *passphrase, chain.Params())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return minerWallet, minerWallet.Unlock(*passphrase)
+}
+
+func boostNetwork() error {
+	var spec *core.GenesisSpec
+	if *genesisPath != "" {
+		loaded, err := core.LoadGenesisSpec(*genesisPath)
+		if err != nil {
+			return err
+		}
+		spec = loaded
+	} else {
+		// 1. create the initial user of blockchain
+		firstUser := role.CreateBoostUser(&config.TestNetParams)
+
+		// 2. premine the reward of one block to the initial user
+		spec = &core.GenesisSpec{
+			ChainID:     "mini-blockchain-simnet",
+			Network:     config.TestNetParams.Name,
+			TimeStampMs: clock.NowMs(),
+			Allocations: []core.GenesisAllocation{{Address: firstUser.Address.String(), Value: config.TestNetParams.MinerReward}},
+		}
+	}
+
+	// 3. boost the blockchain from the genesis spec
+	var err error
+	chain, err = core.InitializeBlockchainFromGenesisWithClock(spec, clock)
+	if err == nil && *addressIndex {
+		chain.EnableAddressIndex()
+	}
+	return err
 }
 
 func boostUsers() {
-	// create 10 users
+	// create 10 users, mixing the signature schemes
+	keyTypes := []util.KeyType{util.KeyTypeEd25519, util.KeyTypeSecp256k1, util.KeyTypeRSA}
 	for i := 0; i < userCount; i++ {
-		user := role.CreateUser(chain)
+		user := role.CreateUserWithKeyType(chain, keyTypes[i%len(keyTypes)])
 		users = append(users, user)
-		util.GetMainLogger().Infof("User %v created\n", user.GetShortIdentity())
+		util.GetMainLogger().Infof("User %v created\n", user.GetIdentity())
 	}
 }
 
 func startTrading() {
-	s1 := rand.NewSource(time.Now().UnixNano())
+	s1 := rand.NewSource(int64(clock.NowMs()))
 	r1 := rand.New(s1)
 
 	block := miner.GetBlockChain().GetLatestBlock()
@@ -64,63 +110,67 @@ func startTrading() {
 		}
 
 		if block != nil {
-			time.Sleep(500 * time.Millisecond)
+			clock.Sleep(500 * time.Millisecond)
 		}
 
-		amount := r1.Intn(config.MinerRewardBase / 1000)
+		amount := r1.Intn(int(chain.Params().MinerReward / 1000))
 		fee := r1.Intn(10)
-		if couldUserPostTransaction(miner.Address) && int(miner.GetBlockChain().BalanceOf(&miner.Address)) > amount {
+		if int(miner.SpendableBalance()) > amount {
 			miner.SendTo(users[to], uint64(amount), uint64(fee))
-			time.Sleep(1 * time.Second)
+			clock.Sleep(1 * time.Second)
 		}
 
-		amount = r1.Intn(config.MinerRewardBase / 1000)
+		amount = r1.Intn(int(chain.Params().MinerReward / 1000))
 		fee = r1.Intn(userCount)
-		if couldUserPostTransaction(users[from].Address) && int(miner.GetBlockChain().BalanceOf(&users[from].Address)) > amount {
+		if int(users[from].SpendableBalance()) > amount {
 			users[from].SendTo(users[to], uint64(amount), uint64(fee))
-			time.Sleep(1 * time.Second)
+			clock.Sleep(1 * time.Second)
 		}
 	}
 }
 
 func initializeOneMinerAndStartMining() {
-	miner = role.CreateMiner(chain)
+	if *keystorePath == "" {
+		miner = role.CreateMiner(chain)
+	} else {
+		minerWallet, err := loadMinerWallet()
+		if err == nil {
+			miner, err = role.CreateMinerWithWallet(chain, minerWallet)
+		}
+		if err != nil {
+			util.GetMainLogger().Errorf("Failed to load the keystore %s: %s\n", *keystorePath, err)
+			return
+		}
+	}
 	miner.StartMining()
 }
 
 func printStatus() {
 	for {
 		var buffer bytes.Buffer
-		buffer.WriteString(fmt.Sprintf("Miner[%s:%d]] ", miner.GetShortIdentity(), miner.GetBlockChain().BalanceOf(&miner.Address)))
+		buffer.WriteString(fmt.Sprintf("Miner[%s:%d]] ", miner.GetIdentity(), miner.Balance()))
 
 		for i := 0; i < userCount; i++ {
-			buffer.WriteString(fmt.Sprintf("User[%s:%d]] ", users[i].GetShortIdentity(), miner.GetBlockChain().BalanceOf(&users[i].Address)))
+			buffer.WriteString(fmt.Sprintf("User[%s:%d]] ", users[i].GetIdentity(), users[i].Balance()))
 		}
 
 		util.GetMainLogger().Debugf("Account Status: %s\n", buffer.String())
 		//util.GetMainLogger().Debugf("Chain Status: %s\n", miner.GetBlockChain().Print())
 
-		time.Sleep(1 * time.Second)
+		clock.Sleep(1 * time.Second)
 	}
 }
 
-// TODO this implementation doesn't support multiple transactions from same user.
-func couldUserPostTransaction(sender rsa.PublicKey) bool {
-	for _, tran := range miner.GetBlockChain().TransactionPool {
-		if tran.Sender == sender {
-			return false
-		}
-	}
-	return true
-}
-
 /*
  * This function is to simulate the blochchain workflow
  */
 func runSimulator() {
 	// 1. boost the blochchain
 	util.GetMainLogger().Infof("Start to boost blockchain \n")
-	boostNetwork()
+	if err := boostNetwork(); err != nil {
+		util.GetMainLogger().Errorf("Failed to boost blockchain: %s\n", err)
+		return
+	}
 	util.GetMainLogger().Infof("Finished boosting blockchain \n")
 
 	// 3. initialize a miner to mine the trasaction and generate block
@@ -145,10 +195,72 @@ func runSimulator() {
 	go startTrading()
 
 	for {
-		time.Sleep(10 * time.Second)
+		clock.Sleep(10 * time.Second)
 	}
 }
 
+/*
+ * Run a command instead of the simulator:
+ *   signmessage <address> <message>              sign a message with a key of the keystore
+ *   verifymessage <address> <signature> <message>
+ */
+func runCommand(args []string) error {
+	switch args[0] {
+	case "signmessage":
+		if len(args) != 3 {
+			return errors.New("Usage: signmessage <address> <message>")
+		}
+		if *keystorePath == "" {
+			return errors.New("signmessage needs a keystore")
+		}
+		address, err := util.ParseAddress(args[1])
+		if err != nil {
+			return err
+		}
+		params, err := config.ParamsByName(*network)
+		if err != nil {
+			return err
+		}
+		signer, err := wallet.OpenWallet(*keystorePath, params)
+		if err != nil {
+			return err
+		}
+		if err := signer.Unlock(*passphrase); err != nil {
+			return err
+		}
+		signature, err := signer.SignMessage(address, []byte(args[2]))
+		if err != nil {
+			return err
+		}
+		fmt.Println(signature)
+
+	case "verifymessage":
+		if len(args) != 4 {
+			return errors.New("Usage: verifymessage <address> <signature> <message>")
+		}
+		address, err := util.ParseAddress(args[1])
+		if err != nil {
+			return err
+		}
+		if err := util.VerifyMessage(address, args[2], []byte(args[3])); err != nil {
+			return err
+		}
+		fmt.Println("Signature is valid")
+
+	default:
+		return fmt.Errorf("Unknown command %s", args[0])
+	}
+	return nil
+}
+
 func main() {
+	flag.Parse()
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	runSimulator()
 }
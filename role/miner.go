@@ -1,20 +1,21 @@
 package role
 
 import (
-	"crypto/rsa"
 	"time"
 
 	"github.com/juju/loggo"
 
+	"../config"
 	"../core"
 	"../util"
+	"../wallet"
 )
 
 type Miner struct {
-	chain core.Blockchain
-	key   *rsa.PrivateKey
+	chain  core.Blockchain
+	wallet *wallet.Wallet
 
-	Address         rsa.PublicKey
+	Address         util.Address
 	TransactionPool []*core.Transaction
 }
 
@@ -23,32 +24,53 @@ func CreateMiner(chain core.Blockchain) *Miner {
 
 	var miner Miner
 	miner.Address = user.Address
-	miner.key = user.key
+	miner.wallet = user.wallet
 	miner.chain = chain
 
 	return &miner
 }
 
+/*
+ * CreateMinerWithWallet creates a miner rewarded to the first key of the wallet
+ */
+func CreateMinerWithWallet(chain core.Blockchain, minerWallet *wallet.Wallet) (*Miner, error) {
+	user, err := CreateUserWithWallet(chain, minerWallet)
+	if err != nil {
+		return nil, err
+	}
+
+	var miner Miner
+	miner.Address = user.Address
+	miner.wallet = user.wallet
+	miner.chain = chain
+
+	return &miner, nil
+}
+
 /*
  * StartMining starts to propose and confirm block in the chain
  * Here assume we only have one miner, otherwise this function need to handle multi-threading
  */
 func (miner *Miner) StartMining() {
-	miner.getLogger().Infof("Miner %v starts mining\n", miner.GetShortIdentity())
+	miner.getLogger().Infof("Miner %v starts mining\n", miner.GetIdentity())
 	for i := 0; true; i++ {
-		block := core.CreateNextEmptyBlock(miner.chain.GetLatestBlock(), uint64(time.Now().UnixNano()/1000000), &miner.Address)
-		for _, tran := range miner.chain.TransactionPool {
-			block.AddTransaction(tran)
-			miner.getLogger().Debugf("Added transaction %s\n", tran.Print())
+		clock := miner.chain.Clock()
+		block := core.CreateNextEmptyBlock(miner.chain.Params(), miner.chain.GetLatestBlock(), clock.NowMs(), miner.Address)
+		/* Transactions which are not final yet stay in the pool */
+		trans, fee := miner.chain.SelectPoolTransactions(clock.NowMs())
+		for i := range trans {
+			block.AddTransaction(&trans[i])
+			miner.getLogger().Debugf("Added transaction %s\n", trans[i].Print())
 		}
+		block.Transactions[0].Outputs[0].Value += fee
 
 		var nuance uint64
-		startTime := time.Now()
+		startTimeMs := clock.NowMs()
 
 		for true {
 			//miner.getLogger().Infof("current transaction pool %v\n", miner.chain.PrintTransactionPool())
 
-			block.FinalizeBlockAt(nuance, uint64(time.Now().UnixNano()/1000000))
+			block.FinalizeBlockAt(nuance, clock.NowMs())
 			if miner.chain.ReachDifficulty(block) {
 				miner.getLogger().Debugf("Current chain:%s\n", miner.chain.Print())
 				miner.getLogger().Debugf("Start to confirm block: %s\n", block.Print())
@@ -62,24 +84,56 @@ func (miner *Miner) StartMining() {
 				break
 			} else {
 				//miner.getLogger().Debugf("Not meet difficulty and sleep 1s\n")
-				time.Sleep(1000 * time.Millisecond)
+				clock.Sleep(1000 * time.Millisecond)
 			}
 
 			nuance++
 		}
 
-		miner.getLogger().Infof("Mined %d th block at %s (used time (ms) %d, nuance %d)\n",
-			i+1, time.Now(), (time.Now().UnixNano()-startTime.UnixNano())/1000000, nuance)
+		miner.getLogger().Infof("Mined %d th block at %d (used time (ms) %d, nuance %d)\n",
+			i+1, clock.NowMs(), clock.NowMs()-startTimeMs, nuance)
 		miner.getLogger().Infof("New difficulty: %s \n", miner.chain.GetDifficulty().Print())
 	}
 }
 
-func (miner *Miner) GetShortIdentity() string {
-	return util.GetShortIdentity(miner.Address)
+/*
+ * GenerateBlocks instantly mines n blocks to the miner on a regtest chain
+ * and returns their hashes. It is meant for tests, StartMining is the real loop.
+ */
+func (miner *Miner) GenerateBlocks(n int, includePool bool) ([][config.HashSize]byte, error) {
+	hashes, err := miner.chain.GenerateBlocks(n, miner.Address, includePool)
+	if err != nil {
+		miner.getLogger().Errorf("Failed to generate blocks: %s\n", err)
+	}
+	return hashes, err
+}
+
+/*
+ * Balance of all the keys of the wallet, the change of the payments goes to new keys
+ */
+func (miner *Miner) Balance() uint64 {
+	return miner.wallet.Balance(&miner.chain)
+}
+
+/*
+ * SpendableBalance of all the keys of the wallet, which can be sent in the next block
+ */
+func (miner *Miner) SpendableBalance() uint64 {
+	return miner.wallet.SpendableBalance(&miner.chain)
 }
 
-func (miner *Miner) GetPrivateKey() *rsa.PrivateKey {
-	return miner.key
+func (miner *Miner) GetIdentity() string {
+	return miner.Address.String()
+}
+
+//GetPrivateKey Get the key of the account, nil while the wallet is locked
+func (miner *Miner) GetPrivateKey() util.PrivateKey {
+	key, _ := miner.wallet.Key(miner.Address)
+	return key
+}
+
+func (miner *Miner) GetWallet() *wallet.Wallet {
+	return miner.wallet
 }
 
 func (miner *Miner) GetBlockChain() *core.Blockchain {
@@ -87,19 +141,46 @@ func (miner *Miner) GetBlockChain() *core.Blockchain {
 }
 
 func (miner *Miner) getLogger() loggo.Logger {
-	return util.GetMinerLogger(miner.GetShortIdentity())
+	return util.GetMinerLogger(miner.GetIdentity())
 }
 
 func (miner *Miner) SendTo(receipt *User, amount uint64, fee uint64) {
-	tran, err := miner.chain.TransferCoin(&miner.Address, &receipt.Address, amount, fee)
+	tran, err := miner.wallet.TransferCoin(&miner.chain, receipt.Address, amount, fee)
+	if err != nil {
+		miner.getLogger().Errorf("Failed to create transaction: %v\n", err)
+		return
+	}
+
+	miner.getLogger().Debugf("%s\n", tran.Print())
+	miner.chain.AcceptBroadcastedTransaction(tran)
+	miner.getLogger().Infof("User %v sends %d coins to user %v\n", miner.GetIdentity(), amount, receipt.GetIdentity())
+}
+
+/*
+ * PayOut pays amount to each of the receipts in one transaction, with a fee of feeRate per byte.
+ * Like SendTo, it spends the coins of all the keys of the wallet and sends the change to a new one.
+ */
+func (miner *Miner) PayOut(receipts []*User, amount uint64, feeRate uint64) {
+	payment, err := miner.wallet.NewPayment(&miner.chain, feeRate)
+	if err != nil {
+		miner.getLogger().Errorf("Failed to create transaction: %v\n", err)
+		return
+	}
+	for _, receipt := range receipts {
+		payment.AddOutput(receipt.Address, amount)
+	}
+	tran, selection, err := payment.Build()
 	if err != nil {
 		miner.getLogger().Errorf("Failed to create transaction: %v\n", err)
 		return
 	}
 
-	tran.SignTransaction([]*rsa.PrivateKey{miner.GetPrivateKey()})
+	if err := miner.wallet.SignTransaction(tran, &miner.chain); err != nil {
+		miner.getLogger().Errorf("Failed to sign transaction: %v\n", err)
+		return
+	}
 
 	miner.getLogger().Debugf("%s\n", tran.Print())
 	miner.chain.AcceptBroadcastedTransaction(tran)
-	miner.getLogger().Infof("User %v sends %d coins to user %v\n", miner.GetShortIdentity(), amount, receipt.GetShortIdentity())
+	miner.getLogger().Infof("User %v pays %d coins to %d users with fee %d\n", miner.GetIdentity(), amount, len(receipts), selection.Fee)
 }
@@ -1,27 +1,29 @@
 package role
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-
 	"github.com/juju/loggo"
 
+	"../config"
 	"../core"
 	"../util"
+	"../wallet"
 )
 
+//DefaultKeyType is the signature scheme of users created without a key type
+const DefaultKeyType = util.KeyTypeEd25519
+
 type User struct {
 	chain core.Blockchain
 
-	key     *rsa.PrivateKey
-	Address rsa.PublicKey
+	wallet  *wallet.Wallet
+	Address util.Address
 }
 
 /*
  * CreateBoostUser to create the first user before boosting the chain
  */
-func CreateBoostUser() *User {
-	return createUser()
+func CreateBoostUser(params *config.ChainParams) *User {
+	return createUser(DefaultKeyType, params)
 }
 
 /*
@@ -32,12 +34,22 @@ func (user *User) RegisterBoostUser(chain core.Blockchain) {
 
 	utxoMap := make(map[core.UTXO]bool)
 	chain.RegisterUser(user.Address, utxoMap)
-	user.getLogger().Debugf("Register boost user %v\n", user.GetShortIdentity())
+	user.getLogger().Debugf("Register boost user %v\n", user.GetIdentity())
 
 }
 
 func CreateUser(chain core.Blockchain) *User {
-	user := createUser()
+	return CreateUserWithKeyType(chain, DefaultKeyType)
+}
+
+/*
+ * CreateUserWithKeyType creates a user whose account uses the given signature scheme
+ */
+func CreateUserWithKeyType(chain core.Blockchain, keyType util.KeyType) *User {
+	user := createUser(keyType, chain.Params())
+	if user == nil {
+		return nil
+	}
 	user.chain = chain
 
 	utxoMap := make(map[core.UTXO]bool)
@@ -46,38 +58,81 @@ func CreateUser(chain core.Blockchain) *User {
 	return user
 }
 
-func createUser() *User {
+/*
+ * CreateUserWithWallet creates a user whose account is the first key of the wallet,
+ * a key is generated if the wallet is empty
+ */
+func CreateUserWithWallet(chain core.Blockchain, userWallet *wallet.Wallet) (*User, error) {
 	var user User
+	if err := user.useWallet(userWallet); err != nil {
+		return nil, err
+	}
+	user.chain = chain
 
-	account, err := rsa.GenerateKey(rand.Reader, 1024)
-	if err != nil {
-		user.getLogger().Errorf("Cannot create account: %s\n", err)
-		return nil
+	/* The keys of the wallet may already own coins */
+	for _, address := range userWallet.Addresses() {
+		if _, exist := chain.AddressMap[address]; !exist {
+			chain.RegisterUser(address, make(map[core.UTXO]bool))
+		}
 	}
 
-	user.Address = account.PublicKey
-	user.key = account
+	user.getLogger().Debugf("Created a user at %v\n", user.GetIdentity())
+	return &user, nil
+}
+
+func createUser(keyType util.KeyType, params *config.ChainParams) *User {
+	var user User
 
-	user.getLogger().Debugf("Created a user at %v\n", user.GetShortIdentity())
+	userWallet := wallet.NewMemoryWallet(params)
+	if _, err := userWallet.NewKey(keyType); err != nil {
+		util.GetUserLogger("unknown").Errorf("Cannot create account: %s\n", err)
+		return nil
+	}
+	user.useWallet(userWallet)
+
+	user.getLogger().Debugf("Created a user at %v\n", user.GetIdentity())
 	return &user
 }
 
+func (user *User) useWallet(userWallet *wallet.Wallet) error {
+	addresses := userWallet.Addresses()
+	if len(addresses) == 0 {
+		address, err := userWallet.NewKey(DefaultKeyType)
+		if err != nil {
+			return err
+		}
+		addresses = append(addresses, address)
+	}
+
+	user.wallet = userWallet
+	user.Address = addresses[0]
+	return nil
+}
+
+/*
+ * Balance of all the keys of the wallet, the change of the payments goes to new keys
+ */
 func (user *User) Balance() uint64 {
-	return user.chain.BalanceOf(&user.Address)
+	return user.wallet.Balance(&user.chain)
+}
+
+/*
+ * SpendableBalance of all the keys of the wallet, which can be sent in the next block
+ */
+func (user *User) SpendableBalance() uint64 {
+	return user.wallet.SpendableBalance(&user.chain)
 }
 
 func (user *User) SendTo(receipt *User, amount uint64, fee uint64) {
-	tran, err := user.chain.TransferCoin(&user.Address, &receipt.Address, amount, fee)
+	tran, err := user.wallet.TransferCoin(&user.chain, receipt.Address, amount, fee)
 	if err != nil {
 		user.getLogger().Errorf("Failed to create transaction: %v\n", err)
 		return
 	}
 
-	tran.SignTransaction([]*rsa.PrivateKey{user.GetPrivateKey()})
-
 	user.getLogger().Debugf("%s\n", tran.Print())
 	user.chain.AcceptBroadcastedTransaction(tran)
-	user.getLogger().Infof("User %v sends %d coins to user %v\n", user.GetShortIdentity(), amount, receipt.GetShortIdentity())
+	user.getLogger().Infof("User %v sends %d coins to user %v\n", user.GetIdentity(), amount, receipt.GetIdentity())
 }
 
 /*
@@ -88,14 +143,20 @@ func (user *User) BroadcastTransaction(tran *core.Transaction) {
 	user.chain.AcceptBroadcastedTransaction(tran)
 }
 
-func (user *User) GetShortIdentity() string {
-	return util.GetShortIdentity(user.Address)
+func (user *User) GetIdentity() string {
+	return user.Address.String()
+}
+
+//GetPrivateKey Get the key of the account, nil while the wallet is locked
+func (user *User) GetPrivateKey() util.PrivateKey {
+	key, _ := user.wallet.Key(user.Address)
+	return key
 }
 
-func (user *User) GetPrivateKey() *rsa.PrivateKey {
-	return user.key
+func (user *User) GetWallet() *wallet.Wallet {
+	return user.wallet
 }
 
 func (user *User) getLogger() loggo.Logger {
-	return util.GetUserLogger(user.GetShortIdentity())
+	return util.GetUserLogger(user.GetIdentity())
 }
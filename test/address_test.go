@@ -0,0 +1,90 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+func TestBase58Check(t *testing.T) {
+	for _, data := range [][]byte{{}, {0}, {0, 0, 1}, []byte("Hello world!")} {
+		decoded, err := util.Base58CheckDecode(util.Base58CheckEncode(data))
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("Failed to round trip %x: %x, %v", data, decoded, err)
+		}
+	}
+
+	if util.Base58Encode([]byte{0, 0, 0x39}) != "11z" {
+		t.Errorf("Base58 is incorrect: expected 11z, actual %s", util.Base58Encode([]byte{0, 0, 0x39}))
+	}
+}
+
+func TestAddressFormat(t *testing.T) {
+	user := createTestUser(t)
+	address := addressOf(user)
+
+	parsed, err := util.ParseAddress(address.String())
+	if err != nil || parsed != address {
+		t.Errorf("Failed to parse address %s: %v", address, err)
+	}
+	if parsed.Version() != config.RegTestParams.PubKeyHashAddrID {
+		t.Errorf("Address version is incorrect: expected %d, actual %d", config.RegTestParams.PubKeyHashAddrID, parsed.Version())
+	}
+	if parsed.Hash() != util.HashPublicKey(user.Public()) {
+		t.Error("Address doesn't commit to the hash of the public key")
+	}
+
+	/* Every single character typo is detected */
+	encoded := []byte(address.String())
+	for i := range encoded {
+		typo := append([]byte{}, encoded...)
+		if typo[i] == 'z' {
+			typo[i] = 'y'
+		} else {
+			typo[i] = 'z'
+		}
+		if _, err := util.ParseAddress(string(typo)); err == nil {
+			t.Errorf("Accepted address %s with a typo at %d", typo, i)
+		}
+	}
+
+	if _, err := util.ParseAddress("0OIl"); err == nil {
+		t.Error("Accepted an address with invalid characters")
+	}
+}
+
+func TestAddressOwnership(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* user1 can't spend the coins of user0 */
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 0)
+	tx.SignTransaction([]util.PrivateKey{user1})
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user1), true)
+	if len(chain.GetLatestBlock().Transactions) != 1 {
+		t.Error("Confirmed a transaction signed by another key")
+	}
+
+	/* Addresses of another network are rejected */
+	mainnetAddress := util.NewAddress(config.MainNetParams.PubKeyHashAddrID, user1.Public())
+	tx, _ = chain.TransferCoin(addressOf(user0), mainnetAddress, 1000, 0)
+	tx.SignTransaction([]util.PrivateKey{user0})
+
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user1), true)
+	if len(chain.GetLatestBlock().Transactions) != 1 {
+		t.Error("Confirmed a transaction paying a mainnet address on regtest")
+	}
+
+	/* So is a miner's reward paid to one */
+	block := core.CreateNextEmptyBlock(chain.Params(), chain.GetLatestBlock(), uint64(time.Now().UnixNano()/1000000+1), mainnetAddress)
+	if err := chain.AddBlock(block); err == nil {
+		t.Error("Added a block rewarding a mainnet address on regtest")
+	}
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"../core"
+)
+
+func TestAssetIssuanceAndTransfer(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	balance := chain.BalanceOf(addressOf(user0))
+
+	tx, points, err := chain.IssueAsset(addressOf(user0), "points", 1000, 10)
+	if err != nil {
+		t.Fatalf("Failed to issue an asset: %s", err)
+	}
+	confirmTransaction(t, &chain, user0, tx)
+
+	if chain.BalanceOfAsset(addressOf(user0), points) != 1000 {
+		t.Errorf("Asset balance is incorrect: expected %d, actual %d", 1000, chain.BalanceOfAsset(addressOf(user0), points))
+	}
+	if chain.BalanceOf(addressOf(user0)) != balance-10 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", balance-10, chain.BalanceOf(addressOf(user0)))
+	}
+	asset, exist := chain.AssetOf(points)
+	if !exist || asset.Name != "points" || asset.Issuer != addressOf(user0) || asset.Supply != 1000 {
+		t.Errorf("Asset is incorrect: %v", asset)
+	}
+
+	tx, err = chain.TransferAsset(addressOf(user0), addressOf(user1), points, 300, 5)
+	if err != nil {
+		t.Fatalf("Failed to transfer an asset: %s", err)
+	}
+	confirmTransaction(t, &chain, user0, tx)
+
+	if chain.BalanceOfAsset(addressOf(user1), points) != 300 {
+		t.Errorf("Asset balance is incorrect: expected %d, actual %d", 300, chain.BalanceOfAsset(addressOf(user1), points))
+	}
+	if chain.BalanceOfAsset(addressOf(user0), points) != 700 {
+		t.Errorf("Asset balance is incorrect: expected %d, actual %d", 700, chain.BalanceOfAsset(addressOf(user0), points))
+	}
+	if chain.BalanceOf(addressOf(user1)) != 0 || chain.BalanceOf(addressOf(user0)) != balance-15 {
+		t.Error("Asset transfer moved native coins")
+	}
+
+	/* Fees are paid in native coins only */
+	if _, err := chain.TransferAsset(addressOf(user1), addressOf(user0), points, 100, 5); err == nil {
+		t.Error("Paid a fee without native coins")
+	}
+
+	/* The issuer can issue more, the same name of another issuer is another asset */
+	tx, again, err := chain.IssueAsset(addressOf(user0), "points", 500, 0)
+	if err != nil || again != points {
+		t.Fatalf("Failed to issue more of an asset: %s", err)
+	}
+	confirmTransaction(t, &chain, user0, tx)
+	if asset, _ := chain.AssetOf(points); asset.Supply != 1500 || chain.BalanceOfAsset(addressOf(user0), points) != 1200 {
+		t.Errorf("Asset supply is incorrect: expected %d, actual %d", 1500, asset.Supply)
+	}
+	if core.NewAssetID(addressOf(user1), "points") == points {
+		t.Error("Assets of different issuers share an id")
+	}
+
+	/* Transferring native coins leaves the assets alone */
+	tx, _ = chain.TransferCoin(addressOf(user0), addressOf(user1), balance-15, 0)
+	confirmTransaction(t, &chain, user0, tx)
+	if chain.BalanceOf(addressOf(user0)) != 0 || chain.BalanceOfAsset(addressOf(user0), points) != 1200 {
+		t.Error("Native transfer moved assets")
+	}
+}
+
+func TestAssetConservation(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	tx, points, _ := chain.IssueAsset(addressOf(user0), "points", 1000, 0)
+	confirmTransaction(t, &chain, user0, tx)
+	tx, _ = chain.TransferCoin(addressOf(user0), addressOf(user1), 5000, 0)
+	confirmTransaction(t, &chain, user0, tx)
+
+	/* An asset can't be created by a transfer */
+	inflated, _ := chain.TransferAsset(addressOf(user0), addressOf(user1), points, 300, 0)
+	inflated.Outputs[0].Value += 100
+
+	/* Nor destroyed */
+	burnt, _ := chain.TransferAsset(addressOf(user0), addressOf(user1), points, 300, 0)
+	burnt.Outputs[0].Value -= 100
+
+	/* Nor issued by another key than the issuer's */
+	forged, _, _ := chain.IssueAsset(addressOf(user1), "points", 1000, 0)
+	forged.Outputs[0].Asset = points
+
+	for i, tx := range []*core.Transaction{inflated, burnt, forged} {
+		signer := user0
+		if i == 2 {
+			signer = user1
+		}
+		if mineTransaction(t, &chain, signer, tx) {
+			t.Errorf("Confirmed a transaction which doesn't conserve the asset: %s", tx.Print())
+		}
+	}
+
+	if asset, _ := chain.AssetOf(points); asset.Supply != 1000 {
+		t.Errorf("Asset supply is incorrect: expected %d, actual %d", 1000, asset.Supply)
+	}
+}
+
+func TestAssetOverflow(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	tx, points, _ := chain.IssueAsset(addressOf(user0), "points", 1000, 0)
+	confirmTransaction(t, &chain, user0, tx)
+
+	/* The outputs sum up to 1000 once wrapped around */
+	wrapped, _ := chain.TransferAsset(addressOf(user0), addressOf(user1), points, 1000, 0)
+	wrapped.Outputs[0].Value = math.MaxUint64
+	wrapped.Outputs = append(wrapped.Outputs, core.TransactionOutput{Value: 1001, Address: addressOf(user0), Asset: points})
+	if mineTransaction(t, &chain, user0, wrapped) {
+		t.Error("Confirmed a transaction whose asset outputs overflow")
+	}
+	if chain.BalanceOfAsset(addressOf(user1), points) != 0 {
+		t.Errorf("Asset balance is incorrect: expected %d, actual %d", 0, chain.BalanceOfAsset(addressOf(user1), points))
+	}
+
+	/* Nor can the supply wrap around */
+	overflow, _, _ := chain.IssueAsset(addressOf(user0), "points", math.MaxUint64-999, 0)
+	if mineTransaction(t, &chain, user0, overflow) {
+		t.Error("Confirmed an issuance overflowing the supply")
+	}
+	if asset, _ := chain.AssetOf(points); asset.Supply != 1000 {
+		t.Errorf("Asset supply is incorrect: expected %d, actual %d", 1000, asset.Supply)
+	}
+}
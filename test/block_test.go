@@ -3,6 +3,7 @@ package test
 import (
 	"testing"
 
+	"../config"
 	"../core"
 )
 
@@ -12,7 +13,7 @@ func TestBlock(t *testing.T) {
 		t.Error("Fail to create test transaction")
 	}
 
-	block := core.CreateFirstBlock(0, &users[0].PublicKey)
+	block := core.CreateFirstBlock(&config.RegTestParams, 0, addressOf(users[0]))
 	block.AddTransaction(tran)
 	block.FinalizeBlockAt(0, 0)
 
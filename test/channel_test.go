@@ -0,0 +1,187 @@
+package test
+
+import (
+	"testing"
+
+	"../core"
+	"../util"
+)
+
+/*
+ * Open a channel of capacity funded by alice with bob, and confirm the funding transaction
+ */
+func openTestChannel(t *testing.T, chain *core.Blockchain, alice util.PrivateKey, bob util.PrivateKey, capacity uint64, fee uint64, delay uint32) (*core.Channel, *core.Channel) {
+	aliceChannel, open, fundingTx, err := chain.OpenChannel(alice, bob.Public(), capacity, fee, delay)
+	if err != nil {
+		t.Fatalf("Failed to open a channel: %s", err)
+	}
+	bobChannel, accept, err := chain.AcceptChannel(bob, open)
+	if err != nil {
+		t.Fatalf("Failed to accept a channel: %s", err)
+	}
+	commitment, err := aliceChannel.CompleteOpen(accept)
+	if err != nil {
+		t.Fatalf("Failed to complete the opening of a channel: %s", err)
+	}
+	if err := bobChannel.ReceiveOpenCommitment(commitment); err != nil {
+		t.Fatalf("Failed to receive the first commitment: %s", err)
+	}
+
+	fundingTx.SignTransaction([]util.PrivateKey{alice})
+	chain.AcceptBroadcastedTransaction(fundingTx)
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+	return aliceChannel, bobChannel
+}
+
+/*
+ * Pay amount from one side of a channel to the other
+ */
+func payThroughChannel(t *testing.T, from *core.Channel, to *core.Channel, amount uint64) {
+	commitment, err := from.Pay(amount)
+	if err != nil {
+		t.Fatalf("Failed to pay through the channel: %s", err)
+	}
+	reply, revocation, err := to.ReceivePayment(commitment)
+	if err != nil {
+		t.Fatalf("Failed to receive a payment: %s", err)
+	}
+	revocation, err = from.ReceivePaymentAck(reply, revocation)
+	if err != nil {
+		t.Fatalf("Failed to receive the payment acknowledgement: %s", err)
+	}
+	if err := to.ReceiveRevocation(revocation); err != nil {
+		t.Fatalf("Failed to receive the revocation: %s", err)
+	}
+}
+
+func TestChannelCooperativeClose(t *testing.T) {
+	alice := createTestUser(t)
+	bob := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(alice))
+	aliceBalance := chain.BalanceOf(addressOf(alice))
+
+	aliceChannel, bobChannel := openTestChannel(t, &chain, alice, bob, 10000, 10, 5)
+	if chain.BalanceOf(addressOf(alice)) != aliceBalance-10010 {
+		t.Errorf("Funder balance is incorrect: expected %d, actual %d", aliceBalance-10010, chain.BalanceOf(addressOf(alice)))
+	}
+
+	payThroughChannel(t, aliceChannel, bobChannel, 3000)
+	payThroughChannel(t, bobChannel, aliceChannel, 1000)
+	payThroughChannel(t, aliceChannel, bobChannel, 500)
+
+	if aliceChannel.State != 3 || bobChannel.State != 3 {
+		t.Errorf("Channel state is incorrect: expected %d, actual %d and %d", 3, aliceChannel.State, bobChannel.State)
+	}
+	if aliceChannel.LocalBalance != 7490 || bobChannel.RemoteBalance != 7490 {
+		t.Errorf("Funder channel balance is incorrect: expected %d, actual %d and %d", 7490, aliceChannel.LocalBalance, bobChannel.RemoteBalance)
+	}
+	if bobChannel.LocalBalance != 2500 || aliceChannel.RemoteBalance != 2500 {
+		t.Errorf("Channel balance is incorrect: expected %d, actual %d and %d", 2500, bobChannel.LocalBalance, aliceChannel.RemoteBalance)
+	}
+
+	/* Nothing can be paid beyond the balance, and updates don't touch the chain */
+	if _, err := bobChannel.Pay(2501); err == nil {
+		t.Error("Paid more than the channel balance")
+	}
+	if chain.BalanceOf(addressOf(bob)) != 0 {
+		t.Error("Channel payments reached the chain")
+	}
+
+	/* A closing transaction which doesn't pay the balances is refused */
+	proposal, err := aliceChannel.CloseProposal()
+	if err != nil {
+		t.Fatalf("Failed to propose closing the channel: %s", err)
+	}
+	forged := *proposal
+	forged.Outputs = append([]core.TransactionOutput{}, proposal.Outputs...)
+	forged.Outputs[0].Value++
+	forged.Outputs[1].Value--
+	if _, err := bobChannel.AcceptClose(&forged); err == nil {
+		t.Error("Accepted a closing transaction which doesn't pay the balances")
+	}
+
+	closeTx, err := bobChannel.AcceptClose(proposal)
+	if err != nil {
+		t.Fatalf("Failed to accept closing the channel: %s", err)
+	}
+	chain.AcceptBroadcastedTransaction(closeTx)
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+
+	if chain.BalanceOf(addressOf(bob)) != 2500 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 2500, chain.BalanceOf(addressOf(bob)))
+	}
+	if chain.BalanceOf(addressOf(alice)) != aliceBalance-10010+7490 {
+		t.Errorf("Funder balance is incorrect: expected %d, actual %d", aliceBalance-10010+7490, chain.BalanceOf(addressOf(alice)))
+	}
+}
+
+func TestChannelForceClose(t *testing.T) {
+	alice := createTestUser(t)
+	bob := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(alice))
+
+	aliceChannel, bobChannel := openTestChannel(t, &chain, alice, bob, 10000, 0, 3)
+	payThroughChannel(t, aliceChannel, bobChannel, 4000)
+
+	/* Bob closes alone: Alice is paid at once, Bob waits for the dispute timeout */
+	commitment, err := bobChannel.ForceClose()
+	if err != nil {
+		t.Fatalf("Failed to force close the channel: %s", err)
+	}
+	chain.AcceptBroadcastedTransaction(commitment)
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+
+	if _, err := aliceChannel.Punish(commitment, 0); err == nil {
+		t.Error("Punished the latest commitment")
+	}
+
+	sweep, err := bobChannel.SweepCommitment(commitment, 10)
+	if err != nil {
+		t.Fatalf("Failed to sweep the commitment: %s", err)
+	}
+	chain.AcceptBroadcastedTransaction(sweep)
+	for i := 1; i < 3; i++ {
+		chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+		if chain.BalanceOf(addressOf(bob)) != 0 {
+			t.Errorf("Swept a commitment %d blocks after its confirmation", i)
+		}
+	}
+
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+	if chain.BalanceOf(addressOf(bob)) != 3990 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 3990, chain.BalanceOf(addressOf(bob)))
+	}
+}
+
+func TestChannelPunishRevokedState(t *testing.T) {
+	alice := createTestUser(t)
+	bob := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(alice))
+	aliceBalance := chain.BalanceOf(addressOf(alice))
+
+	aliceChannel, bobChannel := openTestChannel(t, &chain, alice, bob, 10000, 0, 10)
+	payThroughChannel(t, aliceChannel, bobChannel, 6000)
+	revoked, err := bobChannel.ForceClose()
+	if err != nil {
+		t.Fatalf("Failed to force close the channel: %s", err)
+	}
+	payThroughChannel(t, bobChannel, aliceChannel, 5000)
+
+	/* Bob broadcasts the state where Bob had more, Alice takes it all before the timeout */
+	chain.AcceptBroadcastedTransaction(revoked)
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+
+	penalty, err := aliceChannel.Punish(revoked, 0)
+	if err != nil {
+		t.Fatalf("Failed to punish a revoked commitment: %s", err)
+	}
+	chain.AcceptBroadcastedTransaction(penalty)
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+
+	if chain.BalanceOf(addressOf(alice)) != aliceBalance {
+		t.Errorf("Funder balance is incorrect: expected %d, actual %d", aliceBalance, chain.BalanceOf(addressOf(alice)))
+	}
+	if chain.BalanceOf(addressOf(bob)) != 0 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 0, chain.BalanceOf(addressOf(bob)))
+	}
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+func mineTestBlock(chain *core.Blockchain, minerAddress util.Address, timeStampMs uint64) *core.Block {
+	block := core.CreateNextEmptyBlock(chain.Params(), chain.GetLatestBlock(), timeStampMs, minerAddress)
+	for nuance := uint64(0); ; nuance++ {
+		block.FinalizeBlockAt(nuance, timeStampMs)
+		if chain.ReachDifficulty(block) {
+			return block
+		}
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	clock := util.NewFakeClock(1000)
+	clock.Advance(1500 * time.Millisecond)
+	clock.Sleep(time.Second)
+	if clock.NowMs() != 3500 {
+		t.Errorf("Clock is incorrect: expected 3500, actual %d", clock.NowMs())
+	}
+	clock.Set(42)
+	if clock.NowMs() != 42 {
+		t.Errorf("Clock is incorrect: expected 42, actual %d", clock.NowMs())
+	}
+}
+
+func TestBlockTimestampAgainstClock(t *testing.T) {
+	user := createTestUser(t)
+	clock := util.NewFakeClock(1530000000000)
+	var diff NoDifficulty
+	chain := core.InitializeBlockchainWithParams(&config.RegTestParams, addressOf(user), diff, clock)
+
+	block := core.CreateNextEmptyBlock(chain.Params(), chain.GetLatestBlock(), clock.NowMs()+config.RegTestParams.MaxFutureBlockTimeMs+1, addressOf(user))
+	if err := chain.AddBlock(block); err == nil {
+		t.Error("Accepted a block too far in the future")
+	}
+
+	clock.Advance(time.Millisecond)
+	if err := chain.AddBlock(block); err != nil {
+		t.Errorf("Failed to add a valid block: %s", err)
+	}
+}
+
+func TestSimpleDifficultyRetarget(t *testing.T) {
+	user := createTestUser(t)
+	clock := util.NewFakeClock(1530000000000)
+
+	params := config.RegTestParams
+	params.DifficultyAlgorithm = config.DifficultySimple
+	params.TargetBlockIntervalMs = 1000
+	params.InitialDifficulty = 0.25
+	diff, _ := core.CreateDifficulty(&params)
+	chain := core.InitializeBlockchainWithParams(&params, addressOf(user), diff, clock)
+
+	/* The block took twice the target interval, so the difficulty halves */
+	clock.Advance(2 * time.Second)
+	if err := chain.AddBlock(mineTestBlock(&chain, addressOf(user), clock.NowMs())); err != nil {
+		t.Fatalf("Failed to add a valid block: %s", err)
+	}
+
+	var below, above [config.HashSize]byte
+	below[0] = 0x7f
+	above[0] = 0x80
+	above[config.HashSize-1] = 0x01
+	if !chain.GetDifficulty().ReachDifficulty(below) || chain.GetDifficulty().ReachDifficulty(above) {
+		t.Errorf("Difficulty is incorrect: %s", chain.GetDifficulty().Print())
+	}
+
+	/* The block took a quarter of the target interval, so the difficulty is 4 times harder */
+	clock.Advance(250 * time.Millisecond)
+	if err := chain.AddBlock(mineTestBlock(&chain, addressOf(user), clock.NowMs())); err != nil {
+		t.Fatalf("Failed to add a valid block: %s", err)
+	}
+
+	below[0] = 0x1f
+	above[0] = 0x20
+	if !chain.GetDifficulty().ReachDifficulty(below) || chain.GetDifficulty().ReachDifficulty(above) {
+		t.Errorf("Difficulty is incorrect: %s", chain.GetDifficulty().Print())
+	}
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"../core"
+)
+
+var testCoinSelectors = []core.CoinSelector{
+	core.LargestFirst{},
+	core.SmallestFirst{},
+	core.BranchAndBound{},
+	core.RandomImprove{Rand: rand.New(rand.NewSource(1))},
+}
+
+func createTestCoins(values ...uint64) []core.Coin {
+	coins := make([]core.Coin, len(values))
+	for i, value := range values {
+		coins[i].Value = value
+	}
+	return coins
+}
+
+func coinValues(selection *core.CoinSelection) []uint64 {
+	values := make([]uint64, len(selection.Coins))
+	for i, coin := range selection.Coins {
+		values[i] = coin.Value
+	}
+	return values
+}
+
+func TestCoinSelectors(t *testing.T) {
+	coins := createTestCoins(5, 1, 20, 2, 10)
+	cases := []struct {
+		selector core.CoinSelector
+		target   core.CoinTarget
+		values   []uint64
+		change   uint64
+		fee      uint64
+	}{
+		{core.LargestFirst{}, core.CoinTarget{Amount: 12, Fee: 1}, []uint64{20}, 7, 1},
+		{core.SmallestFirst{}, core.CoinTarget{Amount: 12, Fee: 1}, []uint64{1, 2, 5, 10}, 5, 1},
+		/* Coins not worth the fee of their input are skipped */
+		{core.LargestFirst{}, core.CoinTarget{Amount: 25, FeePerInput: 1}, []uint64{20, 10}, 3, 2},
+		{core.SmallestFirst{}, core.CoinTarget{Amount: 5, FeePerInput: 1}, []uint64{2, 5}, 0, 2},
+		{core.BranchAndBound{}, core.CoinTarget{Amount: 13, Fee: 2}, []uint64{10, 5}, 0, 2},
+		{core.BranchAndBound{}, core.CoinTarget{Amount: 10, FeePerInput: 1}, []uint64{10, 2}, 0, 2},
+		/* Change smaller than MinChange is left to the fee */
+		{core.BranchAndBound{}, core.CoinTarget{Amount: 14, MinChange: 2}, []uint64{10, 5}, 0, 1},
+		{core.LargestFirst{}, core.CoinTarget{Amount: 19, ChangeFee: 1, MinChange: 2}, []uint64{20}, 0, 1},
+		{core.LargestFirst{}, core.CoinTarget{Amount: 16, ChangeFee: 1, MinChange: 2}, []uint64{20}, 3, 1},
+		/* No exact match, BranchAndBound falls back */
+		{core.BranchAndBound{}, core.CoinTarget{Amount: 14}, []uint64{20}, 6, 0},
+		{core.BranchAndBound{Fallback: core.SmallestFirst{}}, core.CoinTarget{Amount: 14}, []uint64{1, 2, 5, 10}, 4, 0},
+	}
+	for i, c := range cases {
+		selection, err := c.selector.SelectCoins(coins, c.target)
+		if err != nil {
+			t.Errorf("Case %d failed to select coins: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(coinValues(selection), c.values) {
+			t.Errorf("Case %d selected incorrect coins: expected %v, actual %v", i, c.values, coinValues(selection))
+		}
+		if selection.Change != c.change || selection.Fee != c.fee {
+			t.Errorf("Case %d change and fee are incorrect: expected %d and %d, actual %d and %d", i, c.change, c.fee, selection.Change, selection.Fee)
+		}
+	}
+}
+
+func TestCoinSelectionBalance(t *testing.T) {
+	coins := createTestCoins(5, 1, 20, 2, 10, 7, 3)
+	target := core.CoinTarget{Amount: 17, Fee: 2, FeePerInput: 1, ChangeFee: 1}
+	for _, selector := range testCoinSelectors {
+		selection, err := selector.SelectCoins(coins, target)
+		if err != nil {
+			t.Errorf("%T failed to select coins: %s", selector, err)
+			continue
+		}
+		var total uint64
+		for _, coin := range selection.Coins {
+			total += coin.Value
+		}
+		if total != selection.Total || total != target.Amount+selection.Change+selection.Fee {
+			t.Errorf("%T selection doesn't balance: %d in, %d paid, %d change, %d fee", selector, total, target.Amount, selection.Change, selection.Fee)
+		}
+		if selection.Fee < target.Fee+uint64(len(selection.Coins))*target.FeePerInput {
+			t.Errorf("%T fee is too low: %d", selector, selection.Fee)
+		}
+
+		if _, err := selector.SelectCoins(coins, core.CoinTarget{Amount: 49}); err == nil {
+			t.Errorf("%T selected more than the coins are worth", selector)
+		}
+		if _, err := selector.SelectCoins(nil, core.CoinTarget{}); err == nil {
+			t.Errorf("%T selected no coin", selector)
+		}
+	}
+}
+
+func TestRandomImprove(t *testing.T) {
+	coins := createTestCoins(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+	target := core.CoinTarget{Amount: 10}
+
+	first, _ := core.RandomImprove{Rand: rand.New(rand.NewSource(7))}.SelectCoins(coins, target)
+	second, _ := core.RandomImprove{Rand: rand.New(rand.NewSource(7))}.SelectCoins(coins, target)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Same random source selected different coins")
+	}
+
+	/* Only the coin reaching the target may make the change more than twice the amount, never the improvement */
+	for seed := int64(0); seed < 20; seed++ {
+		selection, err := core.RandomImprove{Rand: rand.New(rand.NewSource(seed))}.SelectCoins(coins, target)
+		if err != nil {
+			t.Fatalf("Failed to select coins: %s", err)
+		}
+		last := selection.Coins[len(selection.Coins)-1].Value
+		if selection.Change > 2*target.Amount && selection.Change-last >= target.Amount {
+			t.Errorf("Change is incorrect: %d with coins %v", selection.Change, coinValues(selection))
+		}
+	}
+}
+
+func TestTransferCoinWith(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	user2 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	for _, amount := range []uint64{1000, 2000, 3000} {
+		tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), amount, 10)
+		confirmTransaction(t, &chain, user0, tx)
+	}
+
+	/* The fee counts in the balance */
+	if _, err := chain.TransferCoin(addressOf(user1), addressOf(user2), 6000, 10); err == nil {
+		t.Error("Transferred the whole balance without the fee")
+	}
+
+	tx, selection, err := chain.TransferCoinWith(addressOf(user1), addressOf(user2), 2990, 10, core.BranchAndBound{})
+	if err != nil {
+		t.Fatalf("Failed to transfer: %s", err)
+	}
+	if len(tx.Inputs) != 1 || len(tx.Outputs) != 1 || selection.Change != 0 || selection.Fee != 10 {
+		t.Errorf("Exact match is incorrect: %d inputs, %d outputs, change %d", len(tx.Inputs), len(tx.Outputs), selection.Change)
+	}
+
+	tx, selection, err = chain.TransferCoinWith(addressOf(user1), addressOf(user2), 2500, 10, core.SmallestFirst{})
+	if err != nil {
+		t.Fatalf("Failed to transfer: %s", err)
+	}
+	if len(tx.Inputs) != 2 || selection.Change != 490 || tx.Outputs[1].Value != 490 {
+		t.Errorf("Smallest first transfer is incorrect: %d inputs, change %d", len(tx.Inputs), selection.Change)
+	}
+	confirmTransaction(t, &chain, user1, tx)
+	if chain.BalanceOf(addressOf(user2)) != 2500 || chain.BalanceOf(addressOf(user1)) != 3490 {
+		t.Errorf("Balances are incorrect: expected %d and %d, actual %d and %d", 2500, 3490, chain.BalanceOf(addressOf(user2)), chain.BalanceOf(addressOf(user1)))
+	}
+}
@@ -3,18 +3,22 @@ package test
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"testing"
 
+	"../config"
+	"../core"
 	"../util"
 )
 
 func TestSign(t *testing.T) {
 	message := []byte("Hello world!")
 
-	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
 	if err != nil {
 		t.Errorf("Failed to generate keys %s\n", err)
 	}
+	priv := util.NewRSAPrivateKey(key)
 
 	signature, err := util.Sign(message, priv)
 
@@ -22,14 +26,82 @@ func TestSign(t *testing.T) {
 		t.Errorf("Failed to sign message %s\n", err)
 	}
 
-	if util.VerifySignature(message, signature, &priv.PublicKey) != nil {
+	if util.VerifySignature(message, signature, priv.Public()) != nil {
 		t.Errorf("Signature expected true, actual false")
 	}
 
-	priv1, err := rsa.GenerateKey(rand.Reader, 1024)
-	signature1, err := util.Sign(message, priv1)
+	key1, err := rsa.GenerateKey(rand.Reader, 1024)
+	signature1, err := util.Sign(message, util.NewRSAPrivateKey(key1))
 
-	if util.VerifySignature(message, signature1, &priv.PublicKey) == nil {
+	if util.VerifySignature(message, signature1, priv.Public()) == nil {
 		t.Error("Signature expect false, actual true")
 	}
 }
+
+func TestSignatureSchemes(t *testing.T) {
+	message := []byte("Hello world!")
+
+	for _, keyType := range []util.KeyType{util.KeyTypeRSA, util.KeyTypeEd25519, util.KeyTypeSecp256k1} {
+		priv, err := util.GenerateKey(keyType)
+		if err != nil {
+			t.Fatalf("Failed to generate key of type %d: %s", keyType, err)
+		}
+		if priv.Public().Type() != keyType {
+			t.Errorf("Public key has type %d, expected %d", priv.Public().Type(), keyType)
+		}
+
+		pub, err := util.ParsePublicKey(priv.Public().Bytes())
+		if err != nil || pub != priv.Public() {
+			t.Errorf("Failed to parse public key of type %d: %v", keyType, err)
+		}
+
+		signature, err := util.Sign(message, priv)
+		if err != nil {
+			t.Fatalf("Failed to sign message with key type %d: %s", keyType, err)
+		}
+		if util.VerifySignature(message, signature, priv.Public()) != nil {
+			t.Errorf("Failed to verify signature of key type %d", keyType)
+		}
+		if util.VerifySignature([]byte("Hello world?"), signature, priv.Public()) == nil {
+			t.Errorf("Verified a forged message with key type %d", keyType)
+		}
+		if size, err := util.MaxSignatureSize(priv.Public()); err != nil || len(signature) > size {
+			t.Errorf("Signature of key type %d is longer than the maximum: %d, %v", keyType, len(signature), err)
+		}
+
+		other, _ := util.GenerateKey(keyType)
+		if util.VerifySignature(message, signature, other.Public()) == nil {
+			t.Errorf("Verified a signature with the wrong key of type %d", keyType)
+		}
+	}
+
+	if _, err := util.ParsePublicKey([]byte{42, 1, 2, 3}); err == nil {
+		t.Error("Parsed a public key of an unknown type")
+	}
+}
+
+func TestTransactionMixedKeyTypes(t *testing.T) {
+	user0, _ := util.GenerateKey(util.KeyTypeEd25519)
+	user1, _ := util.GenerateKey(util.KeyTypeSecp256k1)
+	user2 := createTestUser(t)
+
+	chain, _ := core.InitializeBlockchain(&config.RegTestParams, addressOf(user0))
+	chain.GenerateBlocks(1, addressOf(user1), false)
+
+	/* Spend the Ed25519 and the secp256k1 rewards in one transaction */
+	tx := core.CreateTransaction(2, 1)
+	for i, block := range []*core.Block{chain.GetNLatestBlock(2), chain.GetLatestBlock()} {
+		tx.Inputs[i].PrevtxMap = sha256.Sum256(block.Transactions[0].GetRawDataToHashForTest())
+	}
+	tx.Outputs[0].Address = addressOf(user2)
+	tx.Outputs[0].Value = chain.Params().MinerReward * 2
+	tx.SignTransaction([]util.PrivateKey{user0, user1})
+	chain.AcceptBroadcastedTransaction(&tx)
+
+	if _, err := chain.GenerateBlocks(1, addressOf(user2), true); err != nil {
+		t.Fatalf("Failed to add a valid block: %s", err)
+	}
+	if chain.BalanceOf(addressOf(user2)) != chain.Params().MinerReward*3 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", chain.Params().MinerReward*3, chain.BalanceOf(addressOf(user2)))
+	}
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"../core"
+)
+
+func createTestGenesisSpecJSON(t *testing.T, chainID string) ([]byte, []uint64) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	values := []uint64{5000, 7000}
+	return []byte(fmt.Sprintf(`{
+		"chainId": %q,
+		"network": "regtest",
+		"timeStampMs": 1530000000000,
+		"difficulty": {"algorithm": "ma", "targetBlockIntervalMs": 10000, "prob": 0.2, "maSamples": 16},
+		"allocations": [
+			{"address": %q, "value": %d},
+			{"address": %q, "value": %d}
+		]
+	}`, chainID,
+		addressOf(user0).String(), values[0],
+		addressOf(user1).String(), values[1])), values
+}
+
+func TestGenesisDeterministic(t *testing.T) {
+	data, _ := createTestGenesisSpecJSON(t, "test-chain")
+
+	spec0, err := core.ParseGenesisSpec(data)
+	if err != nil {
+		t.Fatalf("Failed to parse genesis spec: %s", err)
+	}
+	spec1, err := core.ParseGenesisSpec(data)
+	if err != nil {
+		t.Fatalf("Failed to parse genesis spec: %s", err)
+	}
+
+	chain0, err := core.InitializeBlockchainFromGenesis(spec0)
+	if err != nil {
+		t.Fatalf("Failed to initialize blockchain: %s", err)
+	}
+	chain1, err := core.InitializeBlockchainFromGenesis(spec1)
+	if err != nil {
+		t.Fatalf("Failed to initialize blockchain: %s", err)
+	}
+
+	if chain0.GetLatestBlock().GetBlockHash() != chain1.GetLatestBlock().GetBlockHash() {
+		t.Error("Same genesis spec produced different genesis hashes")
+	}
+	if !chain0.GetLatestBlock().VerifyBlockHash() {
+		t.Error("Failed to verify genesis block hash")
+	}
+	if chain0.GetChainID() != "test-chain" {
+		t.Errorf("Chain id is incorrect: expected test-chain, actual %s", chain0.GetChainID())
+	}
+
+	spec1.ChainID = "other-chain"
+	block, err := core.CreateGenesisBlock(spec1)
+	if err != nil {
+		t.Fatalf("Failed to create genesis block: %s", err)
+	}
+	if block.GetBlockHash() == chain0.GetLatestBlock().GetBlockHash() {
+		t.Error("Different chain ids produced the same genesis hash")
+	}
+}
+
+func TestGenesisAllocations(t *testing.T) {
+	data, values := createTestGenesisSpecJSON(t, "test-chain")
+	spec, err := core.ParseGenesisSpec(data)
+	if err != nil {
+		t.Fatalf("Failed to parse genesis spec: %s", err)
+	}
+
+	chain, err := core.InitializeBlockchainFromGenesis(spec)
+	if err != nil {
+		t.Fatalf("Failed to initialize blockchain: %s", err)
+	}
+
+	for i, tx := range chain.GetLatestBlock().Transactions[0].Outputs {
+		if chain.BalanceOf(tx.Address) != values[i] {
+			t.Errorf("User balance is incorrect: expected %d, actual %d", values[i], chain.BalanceOf(tx.Address))
+		}
+	}
+}
+
+func TestGenesisInvalidSpec(t *testing.T) {
+	data, _ := createTestGenesisSpecJSON(t, "")
+	if _, err := core.ParseGenesisSpec(data); err == nil {
+		t.Error("Accepted a genesis spec without chain id")
+	}
+
+	if _, err := core.ParseGenesisSpec([]byte(`{"chainId": "test-chain", "difficulty": {"algorithm": "simple", "targetBlockIntervalMs": 1, "prob": 0.5}}`)); err == nil {
+		t.Error("Accepted a genesis spec without allocations")
+	}
+
+	if _, err := core.ParseGenesisSpec([]byte(`{"chainId": "test-chain", "allocations": [{"address": "00", "value": 1}]}`)); err == nil {
+		t.Error("Accepted a genesis spec with an invalid address")
+	}
+}
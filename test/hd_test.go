@@ -0,0 +1,143 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"../config"
+	"../util"
+	"../wallet"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func createHDWallet(t *testing.T, mnemonic string, keyType util.KeyType) *wallet.Wallet {
+	hdWallet := wallet.NewMemoryWallet(&config.RegTestParams)
+	if err := hdWallet.SetMnemonic(mnemonic, keyType); err != nil {
+		t.Fatalf("Failed to set the mnemonic: %s", err)
+	}
+	return hdWallet
+}
+
+func TestMnemonic(t *testing.T) {
+	mnemonic, err := wallet.NewMnemonic()
+	if err != nil {
+		t.Fatalf("Failed to generate a mnemonic: %s", err)
+	}
+	if len(strings.Fields(mnemonic)) != 12 {
+		t.Errorf("Mnemonic length is incorrect: expected %d, actual %d", 12, len(strings.Fields(mnemonic)))
+	}
+	createHDWallet(t, mnemonic, util.KeyTypeSecp256k1)
+
+	/* The last word carries the checksum */
+	invalid := strings.Replace(testMnemonic, "about", "abandon", 1)
+	if err := wallet.NewMemoryWallet(&config.RegTestParams).SetMnemonic(invalid, util.KeyTypeSecp256k1); err == nil {
+		t.Error("Accepted a mnemonic with a wrong checksum")
+	}
+	if err := wallet.NewMemoryWallet(&config.RegTestParams).SetMnemonic("not a mnemonic", util.KeyTypeSecp256k1); err == nil {
+		t.Error("Accepted words out of the word list")
+	}
+	if err := wallet.NewMemoryWallet(&config.RegTestParams).SetMnemonic(testMnemonic, util.KeyTypeRSA); err == nil {
+		t.Error("Derived RSA keys")
+	}
+
+	used := wallet.NewMemoryWallet(&config.RegTestParams)
+	used.NewKey(util.KeyTypeEd25519)
+	if err := used.SetMnemonic(testMnemonic, util.KeyTypeEd25519); err == nil {
+		t.Error("Set a mnemonic to a wallet which already has keys")
+	}
+}
+
+func TestHDDerivation(t *testing.T) {
+	for _, keyType := range []util.KeyType{util.KeyTypeEd25519, util.KeyTypeSecp256k1} {
+		first := createHDWallet(t, testMnemonic, keyType)
+		second := createHDWallet(t, testMnemonic, keyType)
+
+		seen := make(map[util.Address]bool)
+		for i := 0; i < 3; i++ {
+			receiving, err := first.NewReceivingAddress()
+			if err != nil {
+				t.Fatalf("Failed to derive a key of type %d: %s", keyType, err)
+			}
+			change, _ := first.NewChangeAddress()
+			if again, _ := second.NewReceivingAddress(); again != receiving {
+				t.Errorf("Same mnemonic derived different receiving addresses for key type %d", keyType)
+			}
+			if again, _ := second.NewChangeAddress(); again != change {
+				t.Errorf("Same mnemonic derived different change addresses for key type %d", keyType)
+			}
+			if seen[receiving] || seen[change] || receiving == change {
+				t.Errorf("Derived the same address twice for key type %d", keyType)
+			}
+			seen[receiving] = true
+			seen[change] = true
+
+			key, _ := first.Key(receiving)
+			if key.Type() != keyType {
+				t.Errorf("Derived key type is incorrect: expected %d, actual %d", keyType, key.Type())
+			}
+		}
+	}
+
+	other := createHDWallet(t, testMnemonic, util.KeyTypeEd25519)
+	address, _ := other.NewReceivingAddress()
+	if secp256k1, _ := createHDWallet(t, testMnemonic, util.KeyTypeSecp256k1).NewReceivingAddress(); secp256k1 == address {
+		t.Error("Key types derived the same address")
+	}
+	if _, err := wallet.NewMemoryWallet(&config.RegTestParams).NewReceivingAddress(); err == nil {
+		t.Error("Derived an address in a wallet without seed")
+	}
+}
+
+func TestRecoverWallet(t *testing.T) {
+	user0 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* The lost wallet received coins on its second receiving address and its first change address */
+	lost := createHDWallet(t, testMnemonic, util.KeyTypeSecp256k1)
+	lost.NewReceivingAddress()
+	receiving, _ := lost.NewReceivingAddress()
+	change, _ := lost.NewChangeAddress()
+	for _, address := range []util.Address{receiving, change} {
+		tx, err := chain.TransferCoin(addressOf(user0), address, 1000, 10)
+		if err != nil {
+			t.Fatalf("Failed to fund the wallet: %s", err)
+		}
+		confirmTransaction(t, &chain, user0, tx)
+	}
+
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	recovered, err := wallet.RecoverWallet(path, "correct horse", testMnemonic, util.KeyTypeSecp256k1, &chain)
+	if err != nil {
+		t.Fatalf("Failed to recover the wallet: %s", err)
+	}
+	var balance uint64
+	for _, address := range recovered.Addresses() {
+		balance += chain.BalanceOf(address)
+	}
+	if balance != 2000 || len(recovered.Addresses()) != 2 {
+		t.Errorf("Recovered balance is incorrect: expected %d, actual %d in %d addresses", 2000, balance, len(recovered.Addresses()))
+	}
+
+	/* The seed is kept in the keystore, and the derivation goes on after the used addresses */
+	opened, err := wallet.OpenWallet(path, &config.RegTestParams)
+	if err != nil || !opened.IsHD() {
+		t.Fatalf("Failed to open the recovered wallet: %v", err)
+	}
+	if _, err := opened.NewReceivingAddress(); err == nil {
+		t.Error("Derived an address in a locked wallet")
+	}
+	if err := opened.Unlock("correct horse"); err != nil {
+		t.Fatalf("Failed to unlock the recovered wallet: %s", err)
+	}
+	next, _ := opened.NewReceivingAddress()
+	expected, _ := lost.NewReceivingAddress()
+	if next != expected {
+		t.Error("Recovered wallet derived an incorrect next address")
+	}
+
+	if _, err := wallet.RecoverWallet("", "", "not a mnemonic", util.KeyTypeSecp256k1, &chain); err == nil {
+		t.Error("Recovered a wallet from an invalid mnemonic")
+	}
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"testing"
+
+	"../util"
+)
+
+func TestAddressHistory(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	user2 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	if _, err := chain.History(addressOf(user0), 0, 0); err == nil {
+		t.Error("Got the history without address index")
+	}
+
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 10)
+	confirmTransaction(t, &chain, user0, tx)
+
+	/* The blocks before the index is enabled are indexed as well */
+	chain.EnableAddressIndex()
+	tx, _ = chain.TransferCoin(addressOf(user1), addressOf(user2), 500, 10)
+	confirmTransaction(t, &chain, user1, tx)
+
+	history, err := chain.History(addressOf(user1), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to get the history: %s", err)
+	}
+	expected := []struct {
+		credit bool
+		value  uint64
+		height uint64
+	}{{true, 1000, 1}, {false, 1000, 2}, {true, 490, 2}}
+	if len(history) != len(expected) {
+		t.Fatalf("History length is incorrect: expected %d, actual %d", len(expected), len(history))
+	}
+	for i, entry := range history {
+		if entry.Credit != expected[i].credit || entry.Value != expected[i].value || entry.Height != expected[i].height {
+			t.Errorf("History entry %d is incorrect: %+v", i, entry)
+		}
+		block := chain.GetNLatestBlock(int(3 - entry.Height))
+		if entry.TimeStampMs != block.GetTimeStampMs() || entry.Confirmations != 3-entry.Height {
+			t.Errorf("History entry %d has an incorrect block: %+v", i, entry)
+		}
+	}
+	if history[1].TxID != tx.TxID() || history[2].TxID != tx.TxID() {
+		t.Error("History entries have an incorrect transaction")
+	}
+
+	page, _ := chain.History(addressOf(user1), 1, 1)
+	if len(page) != 1 || page[0] != history[1] {
+		t.Errorf("History page is incorrect: %v", page)
+	}
+	if page, _ := chain.History(addressOf(user1), 3, 1); len(page) != 0 {
+		t.Errorf("History page after the end is not empty: %v", page)
+	}
+	if genesis, _ := chain.History(addressOf(user0), 0, 1); len(genesis) != 1 || !genesis[0].Credit || genesis[0].Height != 0 {
+		t.Errorf("Genesis history is incorrect: %v", genesis)
+	}
+
+	/* The index is shared by the copies of the chain */
+	copied := chain
+	copied.GenerateBlocks(1, addressOf(user2), false)
+	if history, _ := chain.History(addressOf(user2), 0, 0); len(history) != 2 || history[1].Value != chain.Params().MinerReward {
+		t.Errorf("History of a block added by a copy is incorrect: %v", history)
+	}
+}
+
+func TestBalanceWithConfirmations(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	user2 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 10)
+	confirmTransaction(t, &chain, user0, tx)
+	chain.GenerateBlocks(2, addressOf(user0), false)
+
+	if balance := chain.BalanceWithConfirmations(addressOf(user1), 3); balance != 1000 {
+		t.Errorf("Balance with 3 confirmations is incorrect: expected %d, actual %d", 1000, balance)
+	}
+	if balance := chain.BalanceWithConfirmations(addressOf(user1), 4); balance != 0 {
+		t.Errorf("Balance with 4 confirmations is incorrect: expected %d, actual %d", 0, balance)
+	}
+
+	/* Pending funds only count without confirmations */
+	tx, _ = chain.TransferCoin(addressOf(user1), addressOf(user2), 400, 10)
+	tx.SignTransaction([]util.PrivateKey{user1})
+	chain.AcceptBroadcastedTransaction(tx)
+	cases := []struct {
+		user     util.PrivateKey
+		minConf  uint64
+		expected uint64
+	}{{user1, 1, 1000}, {user1, 0, 590}, {user2, 1, 0}, {user2, 0, 400}}
+	for _, c := range cases {
+		if balance := chain.BalanceWithConfirmations(addressOf(c.user), c.minConf); balance != c.expected {
+			t.Errorf("Balance with %d confirmations is incorrect: expected %d, actual %d", c.minConf, c.expected, balance)
+		}
+	}
+
+	/* The pending change spent by a chained pending transaction only counts once */
+	tx, _ = chain.TransferCoin(addressOf(user1), addressOf(user2), 100, 10)
+	tx.SignTransaction([]util.PrivateKey{user1})
+	chain.AcceptBroadcastedTransaction(tx)
+	if balance := chain.BalanceWithConfirmations(addressOf(user1), 0); balance != 480 {
+		t.Errorf("Balance with a chained pending transaction is incorrect: expected %d, actual %d", 480, balance)
+	}
+
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if balance := chain.BalanceWithConfirmations(addressOf(user2), 1); balance != 500 {
+		t.Errorf("Confirmed balance is incorrect: expected %d, actual %d", 500, balance)
+	}
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+/*
+ * Mine the valid transactions of the pool on a chain with difficulty, one target interval after the last block
+ */
+func mineTestBlockWithPool(t *testing.T, chain *core.Blockchain, clock *util.FakeClock, minerAddress util.Address) {
+	clock.Advance(time.Duration(chain.Params().TargetBlockIntervalMs) * time.Millisecond)
+	trans, fee := chain.SelectPoolTransactions(clock.NowMs())
+	for nuance := uint64(0); ; nuance++ {
+		block := core.CreateNextBlock(chain.Params(), chain.GetLatestBlock(), clock.NowMs(), minerAddress, nuance, trans)
+		block.Transactions[0].Outputs[0].Value += fee
+		block.FinalizeBlockAt(nuance, clock.NowMs())
+		if chain.ReachDifficulty(block) {
+			if err := chain.AddBlock(block); err != nil {
+				t.Fatalf("Failed to add a valid block: %s", err)
+			}
+			return
+		}
+	}
+}
+
+func TestHTLCRefund(t *testing.T) {
+	alice := createTestUser(t)
+	bob := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(alice))
+
+	htlc := core.HTLC{
+		SecretHash: sha256.Sum256([]byte("secret")),
+		Recipient:  addressOf(bob),
+		Sender:     addressOf(alice),
+		Timeout:    5,
+	}
+	tx, _ := chain.CreateHTLC(&htlc, 5000, 0)
+	tx.SignTransaction([]util.PrivateKey{alice})
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(bob), true)
+
+	if _, err := chain.ClaimHTLC(&htlc, []byte("guess"), bob, 0); err == nil {
+		t.Error("Claimed a contract with the wrong preimage")
+	}
+	if _, err := chain.RefundHTLC(&htlc, bob, 0); err == nil {
+		t.Error("Refunded a contract to the recipient")
+	}
+	if _, err := chain.RefundHTLC(&htlc, alice, 5000); err == nil || !strings.Contains(err.Error(), "fee") {
+		t.Errorf("Refunded a contract whose coins don't cover the fee: %v", err)
+	}
+
+	/* The refund waits in the pool until the timeout */
+	refund, err := chain.RefundHTLC(&htlc, alice, 10)
+	if err != nil {
+		t.Fatalf("Failed to refund the contract: %s", err)
+	}
+	chain.AcceptBroadcastedTransaction(refund)
+
+	/* A later refund spends only the coins locked since, not the ones of the pending refund */
+	tx, _ = chain.CreateHTLC(&htlc, 2000, 0)
+	confirmTransaction(t, &chain, alice, tx)
+	second, err := chain.RefundHTLC(&htlc, alice, 10)
+	if err != nil || second.Outputs[0].Value != 2000-10 {
+		t.Fatalf("Second refund is incorrect: %v", err)
+	}
+	chain.AcceptBroadcastedTransaction(second)
+	if _, err := chain.ClaimHTLC(&htlc, []byte("secret"), bob, 0); err == nil {
+		t.Error("Claimed coins already spent by the pool")
+	}
+	chain.GenerateBlocks(2, addressOf(bob), true)
+	if chain.BalanceOf(chain.ScriptAddress(htlc.Script())) != 7000 {
+		t.Error("Refunded a contract before its timeout")
+	}
+	chain.GenerateBlocks(1, addressOf(bob), true)
+	if chain.BalanceOf(chain.ScriptAddress(htlc.Script())) != 0 {
+		t.Error("Failed to refund the contract after its timeout")
+	}
+	if chain.BalanceOf(addressOf(alice)) != chain.Params().MinerReward-20 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", chain.Params().MinerReward-20, chain.BalanceOf(addressOf(alice)))
+	}
+}
+
+func TestAtomicSwap(t *testing.T) {
+	alice := createTestUser(t)
+	bob := createTestUser(t)
+	miner := createTestUser(t)
+
+	/* Alice has coins on a regtest chain, Bob on a testnet chain */
+	chainA := createRegTestBlockchain(t, 0, addressOf(alice))
+	clock := util.NewFakeClock(1530000000000)
+	diff, _ := core.CreateDifficulty(&config.TestNetParams)
+	chainB := core.InitializeBlockchainWithParams(&config.TestNetParams, addressOf(bob), diff, clock)
+
+	/* Alice knows the secret and locks her coins first, with the longer timeout */
+	secret := []byte("only alice knows")
+	htlcA := core.HTLC{
+		SecretHash: sha256.Sum256(secret),
+		Recipient:  addressOf(bob),
+		Sender:     addressOf(alice),
+		Timeout:    chainA.GetLatestBlock().GetBlockIdx() + 20,
+	}
+	tx, _ := chainA.CreateHTLC(&htlcA, 7000, 0)
+	tx.SignTransaction([]util.PrivateKey{alice})
+	chainA.AcceptBroadcastedTransaction(tx)
+	chainA.GenerateBlocks(1, addressOf(miner), true)
+
+	/* Bob checks the contract on chain A then locks his coins with the same secret hash */
+	if chainA.BalanceOf(chainA.ScriptAddress(htlcA.Script())) != 7000 {
+		t.Fatal("Contract of Alice is not confirmed")
+	}
+	htlcB := core.HTLC{
+		SecretHash: htlcA.SecretHash,
+		Recipient:  addressOf(alice),
+		Sender:     addressOf(bob),
+		Timeout:    chainB.GetLatestBlock().GetBlockIdx() + 10,
+	}
+	tx, _ = chainB.CreateHTLC(&htlcB, 3000, 0)
+	tx.SignTransaction([]util.PrivateKey{bob})
+	chainB.AcceptBroadcastedTransaction(tx)
+	mineTestBlockWithPool(t, &chainB, clock, addressOf(miner))
+
+	/* Alice claims on chain B, revealing the secret */
+	claim, err := chainB.ClaimHTLC(&htlcB, secret, alice, 0)
+	if err != nil {
+		t.Fatalf("Failed to claim on chain B: %s", err)
+	}
+	chainB.AcceptBroadcastedTransaction(claim)
+	mineTestBlockWithPool(t, &chainB, clock, addressOf(miner))
+
+	/* Bob learns the secret from chain B and claims on chain A */
+	revealed, found := chainB.FindHTLCSecret(htlcB.SecretHash)
+	if !found {
+		t.Fatal("Failed to find the secret revealed on chain B")
+	}
+	claim, err = chainA.ClaimHTLC(&htlcA, revealed, bob, 0)
+	if err != nil {
+		t.Fatalf("Failed to claim on chain A: %s", err)
+	}
+	chainA.AcceptBroadcastedTransaction(claim)
+	chainA.GenerateBlocks(1, addressOf(miner), true)
+
+	if chainA.BalanceOf(addressOf(bob)) != 7000 {
+		t.Errorf("Bob balance on chain A is incorrect: expected %d, actual %d", 7000, chainA.BalanceOf(addressOf(bob)))
+	}
+	if chainB.BalanceOf(addressOf(alice)) != 3000 {
+		t.Errorf("Alice balance on chain B is incorrect: expected %d, actual %d", 3000, chainB.BalanceOf(addressOf(alice)))
+	}
+	if chainA.BalanceOf(addressOf(alice)) != chainA.Params().MinerReward-7000 || chainB.BalanceOf(addressOf(bob)) != chainB.Params().MinerReward-3000 {
+		t.Error("Swapped coins don't add up")
+	}
+}
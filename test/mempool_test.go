@@ -0,0 +1,152 @@
+package test
+
+import (
+	"testing"
+
+	"../role"
+	"../util"
+)
+
+func TestSpendUnconfirmedChange(t *testing.T) {
+	user0 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* Each transfer spends the change of the previous one, which is still in the pool */
+	var recipients []util.PrivateKey
+	for i := 0; i < 3; i++ {
+		recipients = append(recipients, createTestUser(t))
+		tx, err := chain.TransferCoin(addressOf(user0), addressOf(recipients[i]), 1000, 10)
+		if err != nil {
+			t.Fatalf("Failed to transfer with a pending transaction: %s", err)
+		}
+		if i > 0 && len(tx.Inputs) != 1 {
+			t.Errorf("Transfer %d doesn't only spend the pending change: %d inputs", i, len(tx.Inputs))
+		}
+		tx.SignTransaction([]util.PrivateKey{user0})
+		chain.AcceptBroadcastedTransaction(tx)
+	}
+	if chain.SpendableBalanceOf(addressOf(user0)) != chain.Params().MinerReward-3030 {
+		t.Errorf("Spendable balance is incorrect: expected %d, actual %d", chain.Params().MinerReward-3030, chain.SpendableBalanceOf(addressOf(user0)))
+	}
+
+	/* The whole chain of Transactions is confirmed in one block */
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+	if len(chain.GetLatestBlock().Transactions) != 4 || len(chain.TransactionPool) != 0 {
+		t.Fatalf("Block is incorrect: %d transactions, %d left in the pool", len(chain.GetLatestBlock().Transactions), len(chain.TransactionPool))
+	}
+	for _, recipient := range recipients {
+		if chain.BalanceOf(addressOf(recipient)) != 1000 {
+			t.Errorf("Recipient balance is incorrect: expected %d, actual %d", 1000, chain.BalanceOf(addressOf(recipient)))
+		}
+	}
+	if chain.BalanceOf(addressOf(user0)) != chain.Params().MinerReward-3030 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", chain.Params().MinerReward-3030, chain.BalanceOf(addressOf(user0)))
+	}
+}
+
+func TestReservePendingSpends(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	user2 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	for _, amount := range []uint64{1000, 2000} {
+		tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), amount, 10)
+		confirmTransaction(t, &chain, user0, tx)
+	}
+
+	first, _ := chain.TransferCoin(addressOf(user1), addressOf(user2), 1500, 10)
+	first.SignTransaction([]util.PrivateKey{user1})
+	chain.AcceptBroadcastedTransaction(first)
+	if chain.SpendableBalanceOf(addressOf(user1)) != 1490 {
+		t.Errorf("Spendable balance is incorrect: expected %d, actual %d", 1490, chain.SpendableBalanceOf(addressOf(user1)))
+	}
+
+	second, err := chain.TransferCoin(addressOf(user1), addressOf(user2), 1400, 10)
+	if err != nil {
+		t.Fatalf("Failed to transfer with a pending transaction: %s", err)
+	}
+	for _, input := range second.Inputs {
+		if input.PrevtxMap == first.Inputs[0].PrevtxMap && input.OutputIndex == first.Inputs[0].OutputIndex {
+			t.Error("Spent an output reserved by a pending transaction")
+		}
+	}
+	signers := make([]util.PrivateKey, len(second.Inputs))
+	for i := range signers {
+		signers[i] = user1
+	}
+	second.SignTransaction(signers)
+	chain.AcceptBroadcastedTransaction(second)
+
+	if _, err := chain.TransferCoin(addressOf(user1), addressOf(user2), 100, 10); err == nil {
+		t.Error("Transferred coins reserved by pending transactions")
+	}
+
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user2)) != 2900 || chain.BalanceOf(addressOf(user1)) != 80 {
+		t.Errorf("Balances are incorrect: expected %d and %d, actual %d and %d", 2900, 80, chain.BalanceOf(addressOf(user2)), chain.BalanceOf(addressOf(user1)))
+	}
+}
+
+func TestUnconfirmedParentRelativeLock(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	parent, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 10)
+	parent.SignTransaction([]util.PrivateKey{user0})
+	chain.AcceptBroadcastedTransaction(parent)
+
+	/* The child waits one block after its parent */
+	child, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 10)
+	child.Inputs[0].Sequence = 1
+	child.SignTransaction([]util.PrivateKey{user0})
+	chain.AcceptBroadcastedTransaction(child)
+
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if len(chain.TransactionPool) != 1 || chain.BalanceOf(addressOf(user1)) != 1000 {
+		t.Errorf("Child with a relative lock time was confirmed with its parent: balance %d", chain.BalanceOf(addressOf(user1)))
+	}
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if len(chain.TransactionPool) != 0 || chain.BalanceOf(addressOf(user1)) != 2000 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 2000, chain.BalanceOf(addressOf(user1)))
+	}
+}
+
+func TestUserSendsPendingChange(t *testing.T) {
+	user0 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	sender := role.CreateUser(chain)
+	receiver := role.CreateUser(chain)
+	tx, _ := chain.TransferCoin(addressOf(user0), sender.Address, 5000, 10)
+	confirmTransaction(t, &chain, user0, tx)
+
+	/* The wallet signs the inputs spending the pending change */
+	sender.SendTo(receiver, 1000, 10)
+	sender.SendTo(receiver, 1000, 10)
+	sender.SendTo(receiver, 1000, 10)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(receiver.Address) != 3000 || sender.Balance() != 1970 {
+		t.Errorf("Balances are incorrect: expected %d and %d, actual %d and %d", 3000, 1970, chain.BalanceOf(receiver.Address), sender.Balance())
+	}
+}
+
+func TestPendingPaymentsOfOthersNotSpendable(t *testing.T) {
+	alice := createTestUser(t)
+	bob := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(alice))
+
+	/* A payment to Bob that can't be mined before height 1000 */
+	locked, _ := chain.TransferCoin(addressOf(alice), addressOf(bob), 5000, 10)
+	locked.LockTime = 1000
+	locked.SignTransaction([]util.PrivateKey{alice})
+	chain.AcceptBroadcastedTransaction(locked)
+	if chain.SpendableBalanceOf(addressOf(bob)) != 0 {
+		t.Errorf("Spendable balance counts a pending payment of another user: %d", chain.SpendableBalanceOf(addressOf(bob)))
+	}
+	if chain.SpendableBalanceOf(addressOf(alice)) != 0 {
+		t.Errorf("Spendable balance counts the change of a transaction that isn't final: %d", chain.SpendableBalanceOf(addressOf(alice)))
+	}
+	if _, err := chain.TransferCoin(addressOf(bob), addressOf(alice), 1000, 10); err == nil {
+		t.Error("Transferred the coins of a pending payment of another user")
+	}
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"../config"
+	"../util"
+	"../wallet"
+)
+
+func TestSignMessage(t *testing.T) {
+	message := []byte("I own this address")
+	other := createTestUser(t)
+	for _, keyType := range testKeyTypes {
+		key, _ := util.GenerateKey(keyType)
+		address := util.NewAddress(config.RegTestParams.PubKeyHashAddrID, key.Public())
+		signature, err := util.SignMessage(message, key)
+		if err != nil {
+			t.Fatalf("Failed to sign a message with key type %d: %s", keyType, err)
+		}
+		if err := util.VerifyMessage(address, signature, message); err != nil {
+			t.Errorf("Failed to verify a message signature of key type %d: %s", keyType, err)
+		}
+		if util.VerifyMessage(address, signature, []byte("I own another address")) == nil {
+			t.Errorf("Verified a signature of another message with key type %d", keyType)
+		}
+		if util.VerifyMessage(addressOf(other), signature, message) == nil {
+			t.Errorf("Verified a signature for another address with key type %d", keyType)
+		}
+	}
+
+	if util.VerifyMessage(addressOf(other), "not base64!", message) == nil {
+		t.Error("Verified an invalid signature")
+	}
+	if util.VerifyMessage(addressOf(other), base64.StdEncoding.EncodeToString([]byte{0xff, 0xff, 1}), message) == nil {
+		t.Error("Verified a truncated signature")
+	}
+
+	/* A signature of the bare message, as a transaction signature is, doesn't verify */
+	raw, _ := util.Sign(message, other)
+	pub := other.Public().Bytes()
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, uint16(len(pub)))
+	data = append(append(data, pub...), raw...)
+	if util.VerifyMessage(addressOf(other), base64.StdEncoding.EncodeToString(data), message) == nil {
+		t.Error("Verified a signature made without the message prefix")
+	}
+}
+
+func TestWalletSignMessage(t *testing.T) {
+	message := []byte("I own this address")
+	signer := wallet.NewMemoryWallet(&config.RegTestParams)
+	address, _ := signer.NewKey(util.KeyTypeSecp256k1)
+	signature, err := signer.SignMessage(address, message)
+	if err != nil {
+		t.Fatalf("Failed to sign a message: %s", err)
+	}
+	if err := util.VerifyMessage(address, signature, message); err != nil {
+		t.Errorf("Failed to verify a message signature: %s", err)
+	}
+
+	/* A watch-only address has no key to sign with */
+	watched := addressOf(createTestUser(t))
+	signer.ImportAddress(watched)
+	if _, err := signer.SignMessage(watched, message); err == nil {
+		t.Error("Signed a message with a watch-only address")
+	}
+}
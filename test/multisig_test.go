@@ -0,0 +1,93 @@
+package test
+
+import (
+	"testing"
+
+	"../core"
+	"../util"
+)
+
+func TestMultiSigScript(t *testing.T) {
+	var pubs []util.PublicKey
+	for i := 0; i < 3; i++ {
+		pubs = append(pubs, createTestUser(t).Public())
+	}
+
+	script, err := core.MultiSigScript(2, pubs)
+	if err != nil {
+		t.Fatalf("Failed to create multisig script: %s", err)
+	}
+	m, parsed, err := core.ParseMultiSigScript(script)
+	if err != nil || m != 2 || len(parsed) != 3 || parsed[0] != pubs[0] || parsed[2] != pubs[2] {
+		t.Errorf("Failed to parse multisig script: %d of %d, %v", m, len(parsed), err)
+	}
+
+	if _, err := core.MultiSigScript(4, pubs); err == nil {
+		t.Error("Created a multisig script requiring more signatures than keys")
+	}
+	if _, err := core.MultiSigScript(0, pubs); err == nil {
+		t.Error("Created a multisig script requiring no signature")
+	}
+	if _, _, err := core.ParseMultiSigScript(core.PayToPubKeyHashScript(util.HashPublicKey(pubs[0]))); err == nil {
+		t.Error("Parsed a pay to public key hash script as multisig")
+	}
+}
+
+func TestMultiSigTransfer(t *testing.T) {
+	funder := createTestUser(t)
+	receiver := createTestUser(t)
+	var keys []util.PrivateKey
+	var pubs []util.PublicKey
+	for _, keyType := range []util.KeyType{util.KeyTypeEd25519, util.KeyTypeSecp256k1, util.KeyTypeRSA} {
+		key, _ := util.GenerateKey(keyType)
+		keys = append(keys, key)
+		pubs = append(pubs, key.Public())
+	}
+
+	chain := createRegTestBlockchain(t, 0, addressOf(funder))
+	script, _ := core.MultiSigScript(2, pubs)
+	treasury := chain.ScriptAddress(script)
+
+	tx, err := chain.TransferToScript(addressOf(funder), script, 10000, 0)
+	if err != nil {
+		t.Fatalf("Failed to fund the treasury: %s", err)
+	}
+	tx.SignTransaction([]util.PrivateKey{funder})
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(funder), true)
+	if chain.BalanceOf(treasury) != 10000 {
+		t.Fatalf("Treasury balance is incorrect: expected %d, actual %d", 10000, chain.BalanceOf(treasury))
+	}
+
+	tx, err = chain.TransferFromScript(script, addressOf(receiver), 3000, 100)
+	if err != nil {
+		t.Fatalf("Failed to spend from the treasury: %s", err)
+	}
+	if err := tx.SignMultiSigInput(0, script, receiver); err == nil {
+		t.Error("Signed a multisig input with a foreign key")
+	}
+
+	/* One signature is not enough */
+	tx.SignMultiSigInput(0, script, keys[2])
+	pending := *tx
+	pending.Inputs = append([]core.TransactionInput{}, tx.Inputs...)
+	chain.AcceptBroadcastedTransaction(&pending)
+	chain.GenerateBlocks(1, addressOf(funder), true)
+	if chain.BalanceOf(treasury) != 10000 {
+		t.Error("Spent a 2 of 3 multisig output with one signature")
+	}
+
+	/* Signatures can be added in any order */
+	tx.SignMultiSigInput(0, script, keys[0])
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(funder), true)
+	if chain.BalanceOf(addressOf(receiver)) != 3000 {
+		t.Errorf("Receiver balance is incorrect: expected %d, actual %d", 3000, chain.BalanceOf(addressOf(receiver)))
+	}
+	if chain.BalanceOf(treasury) != 10000-3000-100 {
+		t.Errorf("Treasury balance is incorrect: expected %d, actual %d", 10000-3000-100, chain.BalanceOf(treasury))
+	}
+	if chain.GetLatestBlock().Transactions[0].Outputs[0].Value != chain.Params().MinerReward+100 {
+		t.Error("Miner didn't collect the fee of the multisig transaction")
+	}
+}
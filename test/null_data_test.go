@@ -0,0 +1,80 @@
+package test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+func TestNullDataAnchor(t *testing.T) {
+	user0 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	balance := chain.BalanceOf(addressOf(user0))
+
+	document := sha256.Sum256([]byte("contract v1"))
+	tx, err := chain.AnchorData(addressOf(user0), document[:], 10)
+	if err != nil {
+		t.Fatalf("Failed to anchor data: %s", err)
+	}
+	tx.SignTransaction([]util.PrivateKey{user0})
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+
+	anchored := chain.NullDataOf(1)
+	if len(anchored) != 1 || !bytes.Equal(anchored[0].Data, document[:]) || anchored[0].TxID != tx.TxID() {
+		t.Fatalf("Anchored data is incorrect: %v", anchored)
+	}
+	if len(chain.NullDataOf(0)) != 0 || len(chain.NullDataOf(2)) != 0 {
+		t.Error("Found data anchored in another block")
+	}
+
+	/* Only the fee is paid, and the null data output is not spendable */
+	if chain.BalanceOf(addressOf(user0)) != balance-10 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", balance-10, chain.BalanceOf(addressOf(user0)))
+	}
+	if len(chain.AddressMap[util.Address{}]) != 0 {
+		t.Error("Null data output was added to the unspent outputs")
+	}
+
+	spend := createSpendingTransaction(tx.TxID(), addressOf(user0), 0)
+	spend.Inputs[0].OutputIndex = anchored[0].OutputIndex
+	chain.AcceptBroadcastedTransaction(spend)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if len(chain.GetLatestBlock().Transactions) != 1 {
+		t.Error("Spent a null data output")
+	}
+}
+
+func TestNullDataRejected(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	if _, err := chain.AnchorData(addressOf(user0), make([]byte, config.RegTestParams.MaxNullDataSize+1), 0); err == nil {
+		t.Error("Anchored more data than allowed")
+	}
+
+	/* A null data output carrying value burns it */
+	valued, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 5000, 0)
+	valued.Outputs[0] = core.TransactionOutput{Value: 5000, Script: core.NullDataScript([]byte("burn"))}
+
+	oversized, _ := chain.AnchorData(addressOf(user0), nil, 0)
+	oversized.Outputs[0].Script = core.NullDataScript(make([]byte, config.RegTestParams.MaxNullDataSize+1))
+
+	/* Null data doesn't pay to an address */
+	addressed, _ := chain.AnchorData(addressOf(user0), []byte("data"), 0)
+	addressed.Outputs[0].Address = addressOf(user1)
+
+	for _, tx := range []*core.Transaction{valued, oversized, addressed} {
+		tx.SignTransaction([]util.PrivateKey{user0})
+		chain.AcceptBroadcastedTransaction(tx)
+		chain.GenerateBlocks(1, addressOf(user1), true)
+		if len(chain.GetLatestBlock().Transactions) != 1 {
+			t.Errorf("Confirmed an invalid null data output: %s", tx.Print())
+		}
+	}
+}
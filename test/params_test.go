@@ -0,0 +1,146 @@
+package test
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+func TestParamsByName(t *testing.T) {
+	for _, name := range []string{"mainnet", "testnet", "regtest"} {
+		params, err := config.ParamsByName(name)
+		if err != nil {
+			t.Errorf("Failed to find network %s: %s", name, err)
+			continue
+		}
+		if err := params.Validate(); err != nil {
+			t.Errorf("Network %s is invalid: %s", name, err)
+		}
+	}
+
+	if _, err := config.ParamsByName("nonet"); err == nil {
+		t.Error("Found an unknown network")
+	}
+
+	params, _ := config.ParamsByName("regtest")
+	params.CoinbaseMaturity = 42
+	if config.RegTestParams.CoinbaseMaturity == 42 {
+		t.Error("ParamsByName must return a copy")
+	}
+}
+
+func TestRegTestDifficulty(t *testing.T) {
+	user := createTestUser(t)
+	chain, err := core.InitializeBlockchain(&config.RegTestParams, addressOf(user))
+	if err != nil {
+		t.Fatalf("Failed to initialize blockchain: %s", err)
+	}
+
+	var hash [config.HashSize]byte
+	for i := range hash {
+		hash[i] = 0xff
+	}
+	if !chain.GetDifficulty().ReachDifficulty(hash) {
+		t.Error("Regtest difficulty must always be met")
+	}
+}
+
+func TestCoinbaseMaturity(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+
+	params := config.RegTestParams
+	params.CoinbaseMaturity = 2
+	chain, err := core.InitializeBlockchain(&params, addressOf(user0))
+	if err != nil {
+		t.Fatalf("Failed to initialize blockchain: %s", err)
+	}
+
+	/* Another chain in the same process keeps its own parameters */
+	other, _ := core.InitializeBlockchain(&config.RegTestParams, addressOf(user0))
+	if other.Params().CoinbaseMaturity != 0 || chain.Params().CoinbaseMaturity != 2 {
+		t.Errorf("Chains share parameters: %d, %d", other.Params().CoinbaseMaturity, chain.Params().CoinbaseMaturity)
+	}
+
+	/* Block 1 rewards user1 */
+	block := core.CreateNextEmptyBlock(chain.Params(), chain.GetLatestBlock(), uint64(time.Now().UnixNano()/1000000+1), addressOf(user1))
+	if err := chain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add a valid block: %s", err)
+	}
+
+	if chain.SpendableBalanceOf(addressOf(user1)) != 0 {
+		t.Errorf("Spendable balance is incorrect: expected 0, actual %d", chain.SpendableBalanceOf(addressOf(user1)))
+	}
+	if _, err := chain.TransferCoin(addressOf(user1), addressOf(user0), 100, 0); err == nil {
+		t.Error("Transferred an immature reward")
+	}
+
+	/* Spending the reward in block 2 is too early */
+	tx := core.CreateTransaction(1, 1)
+	tx.Inputs[0].PrevtxMap = sha256.Sum256(chain.GetLatestBlock().Transactions[0].GetRawDataToHashForTest())
+	tx.Outputs[0].Address = addressOf(user0)
+	tx.Outputs[0].Value = chain.Params().MinerReward
+	tx.SignTransaction([]util.PrivateKey{user1})
+
+	block = core.CreateNextEmptyBlock(chain.Params(), chain.GetLatestBlock(), uint64(time.Now().UnixNano()/1000000+2), addressOf(user0))
+	block.AddTransaction(&tx)
+	if err := chain.AddBlock(block); err == nil {
+		t.Error("Accepted a block spending an immature reward")
+	}
+
+	/* Block 2 and 3 are empty, then the reward can be spent */
+	for i := 2; i <= 3; i++ {
+		block = core.CreateNextEmptyBlock(chain.Params(), chain.GetLatestBlock(), uint64(time.Now().UnixNano()/1000000+int64(i)), addressOf(user0))
+		if err := chain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add a valid block: %s", err)
+		}
+	}
+	if chain.SpendableBalanceOf(addressOf(user1)) != chain.Params().MinerReward {
+		t.Errorf("Spendable balance is incorrect: expected %d, actual %d", chain.Params().MinerReward, chain.SpendableBalanceOf(addressOf(user1)))
+	}
+}
+
+func TestMaxBlockTransactions(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+
+	params := config.RegTestParams
+	params.MaxBlockTransactions = 1
+	chain, _ := core.InitializeBlockchain(&params, addressOf(user0))
+
+	block := core.CreateNextEmptyBlock(chain.Params(), chain.GetLatestBlock(), uint64(time.Now().UnixNano()/1000000+1), addressOf(user1))
+	tx0, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 100, 0)
+	tx0.SignTransaction([]util.PrivateKey{user0})
+	block.AddTransaction(tx0)
+	block.AddTransaction(tx0)
+	if err := chain.AddBlock(block); err == nil {
+		t.Error("Accepted a block with too many transactions")
+	}
+}
+
+func TestMinerRewardParams(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+
+	params := config.RegTestParams
+	params.MinerReward = 5000
+	chain, _ := core.InitializeBlockchain(&params, addressOf(user0))
+	other, _ := core.InitializeBlockchain(&config.RegTestParams, addressOf(user0))
+	if chain.BalanceOf(addressOf(user0)) != 5000 || other.BalanceOf(addressOf(user0)) != config.RegTestParams.MinerReward {
+		t.Errorf("Chains share the miner's reward: %d, %d", chain.BalanceOf(addressOf(user0)), other.BalanceOf(addressOf(user0)))
+	}
+
+	/* A block rewarding its miner with the reward of another chain is rejected */
+	block := core.CreateNextEmptyBlock(other.Params(), chain.GetLatestBlock(), uint64(time.Now().UnixNano()/1000000+1), addressOf(user1))
+	if err := chain.AddBlock(block); err == nil {
+		t.Error("Accepted a block exceeding the miner's reward of the chain")
+	}
+	block = core.CreateNextEmptyBlock(chain.Params(), chain.GetLatestBlock(), uint64(time.Now().UnixNano()/1000000+1), addressOf(user1))
+	if err := chain.AddBlock(block); err != nil || chain.BalanceOf(addressOf(user1)) != 5000 {
+		t.Errorf("Failed to add a block rewarding %d: %v", 5000, err)
+	}
+}
@@ -0,0 +1,187 @@
+package test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"../config"
+	"../core"
+	"../util"
+	"../wallet"
+)
+
+func TestPartialMultiSig(t *testing.T) {
+	funder := createTestUser(t)
+	receiver := createTestUser(t)
+	var keys []util.PrivateKey
+	var pubs []util.PublicKey
+	for _, keyType := range testKeyTypes {
+		key, _ := util.GenerateKey(keyType)
+		keys = append(keys, key)
+		pubs = append(pubs, key.Public())
+	}
+
+	chain := createRegTestBlockchain(t, 0, addressOf(funder))
+	script, _ := core.MultiSigScript(2, pubs)
+	treasury := chain.ScriptAddress(script)
+	tx, _ := chain.TransferToScript(addressOf(funder), script, 10000, 0)
+	confirmTransaction(t, &chain, funder, tx)
+
+	tx, _ = chain.TransferFromScript(script, addressOf(receiver), 3000, 100)
+	partial, err := chain.NewPartialTransaction(*tx)
+	if err != nil {
+		t.Fatalf("Failed to create a partial transaction: %s", err)
+	}
+	if m, signers, err := partial.SignersOf(0); err != nil || m != 2 || len(signers) != 3 {
+		t.Errorf("Signers are incorrect: %d of %d, %v", m, len(signers), err)
+	}
+	if err := partial.SignInput(0, receiver); err == nil {
+		t.Error("Signed a multisig input with a foreign key")
+	}
+	data, err := partial.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode a partial transaction: %s", err)
+	}
+
+	/* Each signer signs their own copy, in any order of the keys */
+	var copies []*core.PartialTransaction
+	for _, key := range []util.PrivateKey{keys[2], keys[0]} {
+		decoded, err := core.DecodePartialTransaction(data)
+		if err != nil {
+			t.Fatalf("Failed to decode a partial transaction: %s", err)
+		}
+		if err := decoded.SignInput(0, key); err != nil {
+			t.Fatalf("Failed to sign a partial transaction: %s", err)
+		}
+		signed, _ := decoded.Encode()
+		decoded, err = core.DecodePartialTransaction(signed)
+		if err != nil {
+			t.Fatalf("Failed to decode a signed partial transaction: %s", err)
+		}
+		copies = append(copies, decoded)
+	}
+
+	if err := partial.Merge(copies[0]); err != nil {
+		t.Fatalf("Failed to merge a partial transaction: %s", err)
+	}
+	if partial.IsComplete() || partial.Finalize() == nil {
+		t.Error("Finalized a 2 of 3 multisig input with one signature")
+	}
+	if _, err := partial.Extract(); err == nil {
+		t.Error("Extracted a transaction not finalized")
+	}
+	partial.Merge(copies[1])
+	partial.Merge(copies[1])
+	if len(partial.Inputs[0].Signatures) != 2 || !partial.IsComplete() {
+		t.Fatalf("Merged signatures are incorrect: %d signatures", len(partial.Inputs[0].Signatures))
+	}
+	if err := partial.Finalize(); err != nil {
+		t.Fatalf("Failed to finalize a partial transaction: %s", err)
+	}
+	signed, err := partial.Extract()
+	if err != nil || signed.TxID() != tx.TxID() {
+		t.Fatalf("Extracted transaction is incorrect: %v", err)
+	}
+
+	chain.AcceptBroadcastedTransaction(signed)
+	chain.GenerateBlocks(1, addressOf(funder), true)
+	if chain.BalanceOf(addressOf(receiver)) != 3000 || chain.BalanceOf(treasury) != 10000-3000-100 {
+		t.Errorf("Balances are incorrect: expected %d and %d, actual %d and %d", 3000, 10000-3000-100, chain.BalanceOf(addressOf(receiver)), chain.BalanceOf(treasury))
+	}
+
+	other, _ := chain.TransferFromScript(script, addressOf(funder), 1000, 100)
+	otherPartial, _ := chain.NewPartialTransaction(*other)
+	if err := partial.Merge(otherPartial); err == nil {
+		t.Error("Merged partial transactions of different transactions")
+	}
+}
+
+func TestPartialOfflineWallet(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	for _, amount := range []uint64{1000, 2000} {
+		tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), amount, 10)
+		confirmTransaction(t, &chain, user0, tx)
+	}
+
+	/* The online machine builds the transaction, the offline one holds the key */
+	tx, _ := chain.TransferCoin(addressOf(user1), addressOf(user0), 2500, 10)
+	partial, err := chain.NewPartialTransaction(*tx)
+	if err != nil {
+		t.Fatalf("Failed to create a partial transaction: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "payment.json")
+	data, _ := partial.Encode()
+	ioutil.WriteFile(path, data, 0600)
+
+	offline := wallet.NewMemoryWallet(&config.RegTestParams)
+	offline.ImportKey(user1)
+	data, _ = ioutil.ReadFile(path)
+	received, err := core.DecodePartialTransaction(data)
+	if err != nil {
+		t.Fatalf("Failed to decode a partial transaction: %s", err)
+	}
+	if n, err := offline.SignPartialTransaction(received); err != nil || n != len(tx.Inputs) {
+		t.Fatalf("Wallet signed %d of %d inputs: %v", n, len(tx.Inputs), err)
+	}
+	data, _ = received.Encode()
+	ioutil.WriteFile(path, data, 0600)
+
+	/* A signature doesn't survive a change of the transaction */
+	tampered := bytes.Replace(data, []byte(`"value": 2500`), []byte(`"value": 2600`), 1)
+	if bytes.Equal(tampered, data) {
+		t.Fatal("Failed to tamper the partial transaction")
+	}
+	if _, err := core.DecodePartialTransaction(tampered); err == nil {
+		t.Error("Decoded a partial transaction with invalid signatures")
+	}
+
+	data, _ = ioutil.ReadFile(path)
+	signed, err := core.DecodePartialTransaction(data)
+	if err != nil {
+		t.Fatalf("Failed to decode a signed partial transaction: %s", err)
+	}
+	if err := partial.Merge(signed); err != nil || partial.Finalize() != nil {
+		t.Fatalf("Failed to finalize a partial transaction: %v", err)
+	}
+	final, _ := partial.Extract()
+	chain.AcceptBroadcastedTransaction(final)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 490 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 490, chain.BalanceOf(addressOf(user1)))
+	}
+}
+
+func TestPartialForgedSpentOutput(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 5000, 10)
+	confirmTransaction(t, &chain, user0, tx)
+
+	/* The offline signer is told the input spends 1010 coins, hiding a fee of 4000 */
+	tx, _ = chain.TransferCoin(addressOf(user1), addressOf(user0), 1000, 10)
+	tx.Outputs = tx.Outputs[:1]
+	partial, _ := chain.NewPartialTransaction(*tx)
+	partial.Inputs[0].PrevOutput.Value = 1010
+	if err := partial.SignInput(0, user1); err != nil {
+		t.Fatalf("Failed to sign a partial transaction: %s", err)
+	}
+	if err := partial.Finalize(); err != nil {
+		t.Fatalf("Failed to finalize a partial transaction: %s", err)
+	}
+
+	forged, _ := partial.Extract()
+	chain.AcceptBroadcastedTransaction(forged)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if len(chain.GetLatestBlock().Transactions) != 1 || chain.BalanceOf(addressOf(user1)) != 5000 {
+		t.Error("Confirmed a signature made for a forged spent output")
+	}
+
+	if _, err := tx.SignatureOf(0, core.SigHashAll|core.SigHashSpentOutput, user1); err == nil {
+		t.Error("Signed the spent output without knowing it")
+	}
+}
@@ -0,0 +1,179 @@
+package test
+
+import (
+	"testing"
+
+	"../config"
+	"../role"
+	"../util"
+	"../wallet"
+)
+
+func TestPaymentFeeRate(t *testing.T) {
+	for _, keyType := range testKeyTypes {
+		payer, _ := util.GenerateKey(keyType)
+		chain := createRegTestBlockchain(t, 0, addressOf(payer))
+
+		payment := chain.NewPayment(payer.Public(), 2)
+		var recipients []util.PrivateKey
+		for i := 0; i < 3; i++ {
+			recipients = append(recipients, createTestUser(t))
+			payment.AddOutput(addressOf(recipients[i]), 1000)
+		}
+		tx, selection, err := payment.Build()
+		if err != nil {
+			t.Fatalf("Failed to build a payment of key type %d: %s", keyType, err)
+		}
+		if len(tx.Outputs) != 4 || selection.Change != tx.Outputs[3].Value || selection.Total != 3000+selection.Change+selection.Fee {
+			t.Errorf("Payment of key type %d is incorrect: %d outputs, change %d", keyType, len(tx.Outputs), selection.Change)
+		}
+
+		/* The fee is estimated before signing and covers the size of the signed transaction */
+		tx.SignTransaction([]util.PrivateKey{payer})
+		if size := uint64(tx.Size()); selection.Fee < 2*size || selection.Fee > 2*size+16 {
+			t.Errorf("Fee of key type %d is incorrect: expected %d, actual %d", keyType, 2*size, selection.Fee)
+		}
+
+		chain.AcceptBroadcastedTransaction(tx)
+		chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+		for _, recipient := range recipients {
+			if chain.BalanceOf(addressOf(recipient)) != 1000 {
+				t.Errorf("Recipient balance is incorrect: expected %d, actual %d", 1000, chain.BalanceOf(addressOf(recipient)))
+			}
+		}
+		if chain.BalanceOf(addressOf(payer)) != chain.Params().MinerReward-3000-selection.Fee {
+			t.Errorf("Payer balance is incorrect: expected %d, actual %d", chain.Params().MinerReward-3000-selection.Fee, chain.BalanceOf(addressOf(payer)))
+		}
+	}
+}
+
+func TestPaymentDust(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 5000, 10)
+	confirmTransaction(t, &chain, user0, tx)
+
+	if _, _, err := chain.NewPayment(user1.Public(), 2).Build(); err == nil {
+		t.Error("Built a payment without output")
+	}
+	if _, _, err := chain.NewPayment(user1.Public(), 2).AddOutput(addressOf(user0), 10).Build(); err == nil {
+		t.Error("Built a payment of a dust output")
+	}
+
+	_, withChange, err := chain.NewPayment(user1.Public(), 2).AddOutput(addressOf(user0), 1000).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a payment: %s", err)
+	}
+
+	/* Paying one more coin leaves less change than the fee of the change output */
+	amount := 5000 - withChange.Fee + 1
+	tx, selection, err := chain.NewPayment(user1.Public(), 2).AddOutput(addressOf(user0), amount).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a payment: %s", err)
+	}
+	if len(tx.Outputs) != 1 || selection.Change != 0 || selection.Fee != 5000-amount {
+		t.Errorf("Dust change is incorrect: %d outputs, change %d, fee %d", len(tx.Outputs), selection.Change, selection.Fee)
+	}
+	confirmTransaction(t, &chain, user1, tx)
+	if chain.BalanceOf(addressOf(user1)) != 0 {
+		t.Errorf("Payer balance is incorrect: expected %d, actual %d", 0, chain.BalanceOf(addressOf(user1)))
+	}
+
+	if _, _, err := chain.NewPayment(user1.Public(), 2).AddOutput(addressOf(user0), 1000).Build(); err == nil {
+		t.Error("Built a payment without coins")
+	}
+}
+
+func TestMinerPayOut(t *testing.T) {
+	minerWallet := wallet.NewMemoryWallet(&config.RegTestParams)
+	address, _ := minerWallet.NewKey(util.KeyTypeSecp256k1)
+	chain := createRegTestBlockchain(t, 0, address)
+	miner, err := role.CreateMinerWithWallet(chain, minerWallet)
+	if err != nil {
+		t.Fatalf("Failed to create a miner: %s", err)
+	}
+
+	var users []*role.User
+	for i := 0; i < 5; i++ {
+		users = append(users, role.CreateUser(chain))
+	}
+	miner.PayOut(users, 2000, 1)
+	miner.GenerateBlocks(1, true)
+
+	block := miner.GetBlockChain().GetLatestBlock()
+	if len(block.Transactions) != 2 || len(block.Transactions[1].Outputs) != len(users)+1 {
+		t.Fatalf("Payout is not one transaction: %d transactions", len(block.Transactions))
+	}
+	for _, user := range users {
+		if chain.BalanceOf(user.Address) != 2000 {
+			t.Errorf("User balance is incorrect: expected %d, actual %d", 2000, chain.BalanceOf(user.Address))
+		}
+	}
+	change := block.Transactions[1].Outputs[len(users)].Address
+	if change == miner.Address || !minerWallet.IsMine(change) {
+		t.Error("Change of the payout doesn't go to a new address of the wallet")
+	}
+}
+
+func TestMinerSendsPendingChange(t *testing.T) {
+	minerWallet := wallet.NewMemoryWallet(&config.RegTestParams)
+	address, _ := minerWallet.NewKey(util.KeyTypeSecp256k1)
+	chain := createRegTestBlockchain(t, 0, address)
+	miner, err := role.CreateMinerWithWallet(chain, minerWallet)
+	if err != nil {
+		t.Fatalf("Failed to create a miner: %s", err)
+	}
+	receiver := role.CreateUser(chain)
+
+	/* The second payment spends the change of the first one, paid to a new address of the wallet */
+	miner.SendTo(receiver, 1000, 10)
+	miner.SendTo(receiver, 1000, 10)
+	if miner.SpendableBalance() != chain.Params().MinerReward-2020 {
+		t.Errorf("Spendable balance is incorrect: expected %d, actual %d", chain.Params().MinerReward-2020, miner.SpendableBalance())
+	}
+	miner.GenerateBlocks(1, true)
+	if len(miner.GetBlockChain().GetLatestBlock().Transactions) != 3 || chain.BalanceOf(receiver.Address) != 2000 {
+		t.Fatalf("Payments were not confirmed: receiver balance %d", chain.BalanceOf(receiver.Address))
+	}
+	if miner.Balance() != 2*chain.Params().MinerReward-2000 || len(minerWallet.Addresses()) != 3 {
+		t.Errorf("Miner balance is incorrect: expected %d, actual %d", 2*chain.Params().MinerReward-2000, miner.Balance())
+	}
+}
+
+func TestWalletPayment(t *testing.T) {
+	funder := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(funder))
+	payerWallet := wallet.NewMemoryWallet(&config.RegTestParams)
+	for i := 0; i < 2; i++ {
+		address, _ := payerWallet.NewKey(util.KeyTypeSecp256k1)
+		tx, _ := chain.TransferCoin(addressOf(funder), address, 3000, 10)
+		confirmTransaction(t, &chain, funder, tx)
+	}
+	recipient := createTestUser(t)
+
+	/* The payment spends the coins of both keys and sends its change to a third one */
+	payment, err := payerWallet.NewPayment(&chain, 1)
+	if err != nil {
+		t.Fatalf("Failed to start a payment: %s", err)
+	}
+	tx, selection, err := payment.AddOutput(addressOf(recipient), 5000).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a payment: %s", err)
+	}
+	if len(tx.Inputs) != 2 || len(tx.Outputs) != 2 || len(payerWallet.Addresses()) != 3 || tx.Outputs[1].Address != payerWallet.Addresses()[2] {
+		t.Fatalf("Payment is incorrect: %d inputs, %d outputs", len(tx.Inputs), len(tx.Outputs))
+	}
+	if err := payerWallet.SignTransaction(tx, &chain); err != nil {
+		t.Fatalf("Failed to sign a payment: %s", err)
+	}
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(funder), true)
+	if chain.BalanceOf(addressOf(recipient)) != 5000 || payerWallet.Balance(&chain) != 1000-selection.Fee {
+		t.Errorf("Balances are incorrect: expected %d and %d, actual %d and %d", 5000, 1000-selection.Fee, chain.BalanceOf(addressOf(recipient)), payerWallet.Balance(&chain))
+	}
+
+	if _, err := wallet.NewMemoryWallet(&config.RegTestParams).NewPayment(&chain, 1); err == nil {
+		t.Error("Started a payment from a wallet without key")
+	}
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"testing"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+func createRegTestBlockchain(t *testing.T, maturity uint64, gensisAddress util.Address) core.Blockchain {
+	params := config.RegTestParams
+	params.CoinbaseMaturity = maturity
+	chain, err := core.InitializeBlockchain(&params, gensisAddress)
+	if err != nil {
+		t.Fatalf("Failed to initialize blockchain: %s", err)
+	}
+	return chain
+}
+
+func TestGenerateBlocks(t *testing.T) {
+	user := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user))
+
+	hashes, err := chain.GenerateBlocks(3, addressOf(user), false)
+	if err != nil {
+		t.Fatalf("Failed to generate blocks: %s", err)
+	}
+	if len(hashes) != 3 || hashes[0] == hashes[1] || hashes[1] == hashes[2] {
+		t.Errorf("Unexpected block hashes %x", hashes)
+	}
+	if chain.GetLatestBlock().GetBlockHash() != hashes[2] {
+		t.Error("The last generated block is not the latest block")
+	}
+	if chain.BalanceOf(addressOf(user)) != chain.Params().MinerReward*4 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", chain.Params().MinerReward*4, chain.BalanceOf(addressOf(user)))
+	}
+
+	mainnet, _ := core.InitializeBlockchain(&config.MainNetParams, addressOf(user))
+	if _, err := mainnet.GenerateBlocks(1, addressOf(user), false); err == nil {
+		t.Error("Generated blocks on a chain with difficulty")
+	}
+}
+
+func TestGenerateBlocksMaturityAndFee(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 2, addressOf(user0))
+
+	chain.GenerateBlocks(1, addressOf(user1), false)
+	if _, err := chain.TransferCoin(addressOf(user1), addressOf(user0), 1000, 10); err == nil {
+		t.Error("Transferred an immature reward")
+	}
+
+	chain.GenerateBlocks(2, addressOf(user0), false)
+	tx, err := chain.TransferCoin(addressOf(user1), addressOf(user0), 1000, 10)
+	if err != nil {
+		t.Fatalf("Failed to transfer a mature reward: %s", err)
+	}
+	tx.SignTransaction([]util.PrivateKey{user1})
+	chain.AcceptBroadcastedTransaction(tx)
+
+	/* An unsigned transaction is left in the pool */
+	invalid, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 0)
+	chain.AcceptBroadcastedTransaction(invalid)
+
+	hashes, err := chain.GenerateBlocks(1, addressOf(user0), true)
+	if err != nil {
+		t.Fatalf("Failed to generate blocks: %s", err)
+	}
+	if len(chain.GetLatestBlock().Transactions) != 2 || chain.GetLatestBlock().GetBlockHash() != hashes[0] {
+		t.Errorf("Expected exactly the valid transaction in the block: %s", chain.GetLatestBlock().Print())
+	}
+	if len(chain.TransactionPool) != 1 {
+		t.Errorf("Expected the invalid transaction to stay in the pool: %s", chain.PrintTransactionPool())
+	}
+
+	expected := uint64(chain.Params().MinerReward*4 + 1000 + 10)
+	if chain.BalanceOf(addressOf(user0)) != expected {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", expected, chain.BalanceOf(addressOf(user0)))
+	}
+	expected = chain.Params().MinerReward - 1000 - 10
+	if chain.BalanceOf(addressOf(user1)) != expected {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", expected, chain.BalanceOf(addressOf(user1)))
+	}
+}
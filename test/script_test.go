@@ -0,0 +1,210 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+/*
+ * Pay value from the coins of funder to an output locked by script, and confirm it
+ */
+func fundScript(t *testing.T, chain *core.Blockchain, funder util.PrivateKey, script core.Script, value uint64) [config.HashSize]byte {
+	tx, err := chain.TransferToScript(addressOf(funder), script, value, 0)
+	if err != nil {
+		t.Fatalf("Failed to fund script: %s", err)
+	}
+	tx.SignTransaction([]util.PrivateKey{funder})
+	chain.AcceptBroadcastedTransaction(tx)
+	if _, err := chain.GenerateBlocks(1, addressOf(funder), true); err != nil {
+		t.Fatalf("Failed to confirm funding: %s", err)
+	}
+	return sha256.Sum256(tx.GetRawDataToHashForTest())
+}
+
+/*
+ * Create a transaction spending the first output of fundTx to address
+ */
+func createSpendingTransaction(fundTx [config.HashSize]byte, address util.Address, value uint64) *core.Transaction {
+	tx := core.CreateTransaction(1, 1)
+	tx.Inputs[0].PrevtxMap = fundTx
+	tx.Outputs[0].Address = address
+	tx.Outputs[0].Value = value
+	return &tx
+}
+
+func TestScriptDisassemble(t *testing.T) {
+	user := createTestUser(t)
+	script := core.PayToPubKeyHashScript(addressOf(user).Hash())
+	hash := addressOf(user).Hash()
+
+	expected := "OP_DUP OP_HASH20 " + hex.EncodeToString(hash[:]) + " OP_EQUALVERIFY OP_CHECKSIG"
+	if script.String() != expected {
+		t.Errorf("Disassembly is incorrect: expected %s, actual %s", expected, script.String())
+	}
+
+	if !core.SignatureScript([]byte{1, 2, 3}, user.Public()).IsPushOnly() || script.IsPushOnly() {
+		t.Error("Push only check is incorrect")
+	}
+
+	long := core.NewScriptBuilder().AddData(make([]byte, 300)).AddInt(16).AddInt(1000).Script()
+	if len(long) != 3+300+1+3 || long.String() != hex.EncodeToString(make([]byte, 300))+" OP_16 e803" {
+		t.Errorf("Pushes are not minimal: %s", long)
+	}
+}
+
+func TestHashLockScript(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	secret := []byte("open sesame")
+	script := core.HashLockScript(sha256.Sum256(secret), addressOf(user1).Hash())
+	fundTx := fundScript(t, &chain, user0, script, 5000)
+
+	/* The wrong preimage is rejected */
+	tx := createSpendingTransaction(fundTx, addressOf(user1), 5000)
+	signature, _ := tx.SignatureOf(0, core.SigHashAll, user1)
+	tx.Inputs[0].Script = core.NewScriptBuilder().AddData(signature).AddData(user1.Public().Bytes()).AddData([]byte("open sesam")).Script()
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 0 {
+		t.Error("Spent a hash lock with the wrong preimage")
+	}
+
+	/* The right preimage signed by another key is rejected */
+	tx = createSpendingTransaction(fundTx, addressOf(user1), 4999)
+	signature, _ = tx.SignatureOf(0, core.SigHashAll, user0)
+	tx.Inputs[0].Script = core.NewScriptBuilder().AddData(signature).AddData(user0.Public().Bytes()).AddData(secret).Script()
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 0 {
+		t.Error("Spent a hash lock with the wrong key")
+	}
+
+	tx = createSpendingTransaction(fundTx, addressOf(user1), 4998)
+	signature, _ = tx.SignatureOf(0, core.SigHashAll, user1)
+	tx.Inputs[0].Script = core.NewScriptBuilder().AddData(signature).AddData(user1.Public().Bytes()).AddData(secret).Script()
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 4998 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 4998, chain.BalanceOf(addressOf(user1)))
+	}
+}
+
+func TestTimeLockScript(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* Funded in block 1, locked until block 4 */
+	fundTx := fundScript(t, &chain, user0, core.TimeLockScript(4, addressOf(user1).Hash()), 5000)
+
+	tx := createSpendingTransaction(fundTx, addressOf(user1), 5000)
+	tx.LockTime = 4
+	signature, _ := tx.SignatureOf(0, core.SigHashAll, user1)
+	tx.Inputs[0].Script = core.SignatureScript(signature, user1.Public())
+	chain.AcceptBroadcastedTransaction(tx)
+
+	/* The lock time of the transaction must reach that of the script */
+	early := createSpendingTransaction(fundTx, addressOf(user1), 4999)
+	early.LockTime = 3
+	signature, _ = early.SignatureOf(0, core.SigHashAll, user1)
+	early.Inputs[0].Script = core.SignatureScript(signature, user1.Public())
+	chain.AcceptBroadcastedTransaction(early)
+
+	for height := 2; height < 4; height++ {
+		chain.GenerateBlocks(1, addressOf(user0), true)
+		if chain.BalanceOf(addressOf(user1)) != 0 {
+			t.Errorf("Spent a time locked output at height %d", height)
+		}
+	}
+
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 5000 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 5000, chain.BalanceOf(addressOf(user1)))
+	}
+
+	/* Lock times from the threshold are timestamps */
+	unlockMs := chain.GetLatestBlock().GetTimeStampMs() + 3*config.RegTestParams.TargetBlockIntervalMs
+	fundTx = fundScript(t, &chain, user0, core.TimeLockScript(unlockMs, addressOf(user1).Hash()), 5000)
+
+	tx = createSpendingTransaction(fundTx, addressOf(user1), 5000)
+	tx.LockTime = unlockMs
+	signature, _ = tx.SignatureOf(0, core.SigHashAll, user1)
+	tx.Inputs[0].Script = core.SignatureScript(signature, user1.Public())
+	chain.AcceptBroadcastedTransaction(tx)
+
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 5000 {
+		t.Error("Spent an output locked by timestamp too early")
+	}
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 10000 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 10000, chain.BalanceOf(addressOf(user1)))
+	}
+}
+
+func TestScriptCostLimit(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	for _, nops := range []int{config.MaxScriptCost + 1, 100} {
+		builder := core.NewScriptBuilder()
+		for i := 0; i < nops; i++ {
+			builder.AddOp(core.OpNop)
+		}
+		fundTx := fundScript(t, &chain, user0, builder.AddInt(1).Script(), 5000)
+
+		/* Anyone can spend it with an empty unlocking script */
+		chain.AcceptBroadcastedTransaction(createSpendingTransaction(fundTx, addressOf(user1), 5000))
+		chain.GenerateBlocks(1, addressOf(user0), true)
+	}
+
+	if chain.BalanceOf(addressOf(user1)) != 5000 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 5000, chain.BalanceOf(addressOf(user1)))
+	}
+}
+
+func TestUndefinedOpcode(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* 0x4e to 0x50 are undefined, not pushes, so a script with them fails */
+	for _, opcode := range []byte{0x4e, 0x4f, 0x50} {
+		script := core.Script{opcode, core.Op1}
+		if script.IsPushOnly() || script.String() != fmt.Sprintf("OP_UNKNOWN_%x OP_1", opcode) {
+			t.Errorf("Opcode %x is parsed as a push: %s", opcode, script)
+		}
+		fundTx := fundScript(t, &chain, user0, script, 1000)
+		chain.AcceptBroadcastedTransaction(createSpendingTransaction(fundTx, addressOf(user1), 1000))
+		chain.GenerateBlocks(1, addressOf(user0), true)
+	}
+
+	if chain.BalanceOf(addressOf(user1)) != 0 {
+		t.Errorf("Spent a script with an undefined opcode: balance %d", chain.BalanceOf(addressOf(user1)))
+	}
+}
+
+func TestScriptAddressMismatch(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* An output locked by a script must pay to the script address */
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 5000, 0)
+	tx.Outputs[0].Script = core.NewScriptBuilder().AddInt(1).Script()
+	tx.SignTransaction([]util.PrivateKey{user0})
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if len(chain.GetLatestBlock().Transactions) != 1 {
+		t.Error("Confirmed an output whose address doesn't commit to its script")
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"../core"
+	"../util"
+)
+
+/*
+ * Sign an input spending a pay to public key hash output
+ */
+func signInput(t *testing.T, tx *core.Transaction, index int, hashType core.SigHashType, signer util.PrivateKey) {
+	signature, err := tx.SignatureOf(index, hashType, signer)
+	if err != nil {
+		t.Fatalf("Failed to sign input %d: %s", index, err)
+	}
+	tx.Inputs[index].Script = core.SignatureScript(signature, signer.Public())
+}
+
+func TestSignatureBindsInputIndex(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	chain.GenerateBlocks(1, addressOf(user0), false)
+
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), chain.Params().MinerReward+1, 0)
+	if len(tx.Inputs) != 2 {
+		t.Fatalf("Expected 2 inputs, actual %d", len(tx.Inputs))
+	}
+	signInput(t, tx, 0, core.SigHashAll, user0)
+	tx.Inputs[1].Script = tx.Inputs[0].Script
+	if tx.VerifyTransaction([]util.PublicKey{user0.Public(), user0.Public()}) == nil {
+		t.Error("Verified a signature copied to another input")
+	}
+
+	signInput(t, tx, 1, core.SigHashAll, user0)
+	if tx.VerifyTransaction([]util.PublicKey{user0.Public(), user0.Public()}) != nil {
+		t.Error("Failed to verify transaction")
+	}
+}
+
+func TestSigHashTypes(t *testing.T) {
+	users, tran, _ := createTestTransaction()
+
+	/* SigHashNone doesn't sign the outputs */
+	signInput(t, tran, 0, core.SigHashNone, users[0])
+	signInput(t, tran, 1, core.SigHashAll, users[1])
+	tran.Outputs[2].Value = 1
+	if tran.VerifyTransaction([]util.PublicKey{users[0].Public(), users[1].Public()}) == nil {
+		t.Error("Verified a forged transaction")
+	}
+	signInput(t, tran, 1, core.SigHashNone, users[1])
+	tran.Outputs[2].Value = 3000
+	if tran.VerifyTransaction([]util.PublicKey{users[0].Public(), users[1].Public()}) != nil {
+		t.Error("Failed to verify outputs changed after SigHashNone")
+	}
+
+	/* SigHashSingle only signs the output of the same index */
+	signInput(t, tran, 0, core.SigHashSingle, users[0])
+	signInput(t, tran, 1, core.SigHashSingle, users[1])
+	tran.Outputs[2].Value = 1
+	if tran.VerifyTransaction([]util.PublicKey{users[0].Public(), users[1].Public()}) != nil {
+		t.Error("Failed to verify an output changed after SigHashSingle")
+	}
+	tran.Outputs[1].Value = 1
+	if tran.VerifyTransaction([]util.PublicKey{users[0].Public(), users[1].Public()}) == nil {
+		t.Error("Verified a forged transaction")
+	}
+
+	/* Unknown hash types are rejected */
+	signInput(t, tran, 0, core.SigHashAll, users[0])
+	signInput(t, tran, 1, core.SigHashAll, users[1])
+	signature, _ := tran.SignatureOf(0, core.SigHashAll, users[0])
+	signature[len(signature)-1] = 0x04
+	tran.Inputs[0].Script = core.SignatureScript(signature, users[0].Public())
+	if tran.VerifyTransaction([]util.PublicKey{users[0].Public(), users[1].Public()}) == nil {
+		t.Error("Verified a signature of an unknown hash type")
+	}
+	if _, err := tran.SignatureOf(2, core.SigHashAll, users[0]); err == nil {
+		t.Error("Signed an input that doesn't exist")
+	}
+}
+
+func TestAnyoneCanPayCrowdfunding(t *testing.T) {
+	backer0 := createTestUser(t)
+	backer1 := createTestUser(t)
+	project := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(backer0))
+	chain.GenerateBlocks(1, addressOf(backer1), false)
+
+	/* The project needs both rewards, each backer pledges one input without knowing the others */
+	pledge := core.CreateTransaction(1, 1)
+	pledge.Inputs[0].PrevtxMap = sha256.Sum256(chain.GetNLatestBlock(2).Transactions[0].GetRawDataToHashForTest())
+	pledge.Outputs[0].Address = addressOf(project)
+	pledge.Outputs[0].Value = chain.Params().MinerReward * 2
+	signInput(t, &pledge, 0, core.SigHashAll|core.SigHashAnyoneCanPay, backer0)
+
+	/* Not enough funds yet */
+	chain.AcceptBroadcastedTransaction(&pledge)
+	chain.GenerateBlocks(1, addressOf(backer0), true)
+	if chain.BalanceOf(addressOf(project)) != 0 {
+		t.Error("Confirmed an underfunded crowdfunding transaction")
+	}
+
+	funded := pledge
+	var input core.TransactionInput
+	input.PrevtxMap = sha256.Sum256(chain.GetNLatestBlock(2).Transactions[0].GetRawDataToHashForTest())
+	funded.Inputs = append(append([]core.TransactionInput{}, pledge.Inputs...), input)
+	signInput(t, &funded, 1, core.SigHashAll|core.SigHashAnyoneCanPay, backer1)
+
+	chain.AcceptBroadcastedTransaction(&funded)
+	chain.GenerateBlocks(1, addressOf(backer0), true)
+	if chain.BalanceOf(addressOf(project)) != chain.Params().MinerReward*2 {
+		t.Errorf("Project balance is incorrect: expected %d, actual %d", chain.Params().MinerReward*2, chain.BalanceOf(addressOf(project)))
+	}
+}
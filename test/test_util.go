@@ -7,6 +7,7 @@ import (
 
 	"../config"
 	"../core"
+	"../util"
 )
 
 type NoDifficulty struct {
@@ -24,24 +25,59 @@ func (d NoDifficulty) Print() string {
 	return ""
 }
 
-func createTestUser(t *testing.T) *rsa.PrivateKey {
+/*
+ * Test users keep RSA keys, the other schemes are covered by crypto_test.go
+ */
+func createTestUser(t *testing.T) util.PrivateKey {
 	user, err := rsa.GenerateKey(rand.Reader, 1024)
 	if err != nil {
 		t.Errorf("Failed to create a user %s", err)
 		return nil
 	}
-	return user
+	return util.NewRSAPrivateKey(user)
+}
+
+/*
+ * Sign every input of tx with user, broadcast it and mine a block, the test stops if the block doesn't contain tx
+ */
+func confirmTransaction(t *testing.T, chain *core.Blockchain, user util.PrivateKey, tx *core.Transaction) {
+	if !mineTransaction(t, chain, user, tx) {
+		t.Fatalf("Transaction was not confirmed: %s", tx.Print())
+	}
+}
+
+/*
+ * Sign every input of tx with user, broadcast it and mine a block, return whether the block contains tx
+ */
+func mineTransaction(t *testing.T, chain *core.Blockchain, user util.PrivateKey, tx *core.Transaction) bool {
+	signers := make([]util.PrivateKey, len(tx.Inputs))
+	for i := range signers {
+		signers[i] = user
+	}
+	if err := tx.SignTransaction(signers); err != nil {
+		t.Fatalf("Failed to sign a transaction: %s", err)
+	}
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(createTestUser(t)), true)
+
+	txID := tx.TxID()
+	for _, mined := range chain.GetLatestBlock().Transactions {
+		if mined.TxID() == txID {
+			return true
+		}
+	}
+	return false
 }
 
-func createTestTransaction() ([]*rsa.PrivateKey, *core.Transaction, error) {
+func createTestTransaction() ([]util.PrivateKey, *core.Transaction, error) {
 	/* Create 4 users */
-	var users []*rsa.PrivateKey
+	var users []util.PrivateKey
 	for i := 0; i < 4; i++ {
 		user, err := rsa.GenerateKey(rand.Reader, 1024)
 		if err != nil {
 			return nil, nil, err
 		}
-		users = append(users, user)
+		users = append(users, util.NewRSAPrivateKey(user))
 	}
 
 	/* Create a transation with 2 Inputs and 3 Outputs */
@@ -50,21 +86,28 @@ func createTestTransaction() ([]*rsa.PrivateKey, *core.Transaction, error) {
 	rand.Read(tran.Inputs[1].PrevtxMap[:])
 
 	tran.Outputs[0].Value = 1000
-	tran.Outputs[0].Address = users[2].PublicKey
+	tran.Outputs[0].Address = addressOf(users[2])
 	tran.Outputs[1].Value = 2000
-	tran.Outputs[1].Address = users[3].PublicKey
+	tran.Outputs[1].Address = addressOf(users[3])
 	tran.Outputs[2].Value = 3000
-	tran.Outputs[2].Address = users[1].PublicKey
+	tran.Outputs[2].Address = addressOf(users[1])
 
 	/* Sign the transaction */
-	tran.SignTransaction([]*rsa.PrivateKey{users[0], users[1]})
+	tran.SignTransaction([]util.PrivateKey{users[0], users[1]})
 	return users, &tran, nil
 }
 
 /*
  * Create a blockchain with gensis block created by an Address
  */
-func createTestBlockchain(gensisAddress *rsa.PublicKey) core.Blockchain {
+func createTestBlockchain(gensisAddress util.Address) core.Blockchain {
 	var diff NoDifficulty
 	return core.InitializeBlockchainWithDiff(gensisAddress, diff)
 }
+
+/*
+ * Get the regtest address of a user
+ */
+func addressOf(user util.PrivateKey) util.Address {
+	return util.NewAddress(config.RegTestParams.PubKeyHashAddrID, user.Public())
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"testing"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+func TestTransactionLockTime(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* Not valid before height 3 */
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 0)
+	tx.LockTime = 3
+	tx.SignTransaction([]util.PrivateKey{user0})
+
+	block := core.CreateNextBlock(chain.Params(), chain.GetLatestBlock(), chain.GetLatestBlock().GetTimeStampMs()+1, addressOf(user0), 0, []core.Transaction{*tx})
+	if err := chain.AddBlock(block); err == nil {
+		t.Error("Accepted a block with a transaction before its lock time")
+	}
+
+	/* The pool holds it until it is final */
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(2, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 0 || len(chain.TransactionPool) != 1 {
+		t.Error("Confirmed a transaction before its lock time")
+	}
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 1000 || len(chain.TransactionPool) != 0 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 1000, chain.BalanceOf(addressOf(user1)))
+	}
+
+	/* Lock times from the threshold are timestamps */
+	tx, _ = chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 0)
+	tx.LockTime = chain.GetLatestBlock().GetTimeStampMs() + 2*config.RegTestParams.TargetBlockIntervalMs
+	tx.SignTransaction([]util.PrivateKey{user0})
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 1000 {
+		t.Error("Confirmed a transaction before its lock time")
+	}
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 2000 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 2000, chain.BalanceOf(addressOf(user1)))
+	}
+
+	/* The lock time is signed */
+	tx, _ = chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 0)
+	tx.LockTime = 100
+	tx.SignTransaction([]util.PrivateKey{user0})
+	tx.LockTime = 0
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 2000 {
+		t.Error("Confirmed a transaction whose lock time was changed after signing")
+	}
+}
+
+func TestRelativeLockTime(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	user2 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* Confirmed at height 1 */
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 0)
+	tx.SignTransaction([]util.PrivateKey{user0})
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+
+	/* Spendable 3 blocks after */
+	tx, _ = chain.TransferCoin(addressOf(user1), addressOf(user2), 1000, 0)
+	tx.Inputs[0].Sequence = 3
+	tx.SignTransaction([]util.PrivateKey{user1})
+	chain.AcceptBroadcastedTransaction(tx)
+	chain.GenerateBlocks(2, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user2)) != 0 {
+		t.Error("Spent an output before its relative lock time")
+	}
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user2)) != 1000 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 1000, chain.BalanceOf(addressOf(user2)))
+	}
+
+	/* Relative lock times in seconds compare the timestamps of the blocks */
+	tx, _ = chain.TransferCoin(addressOf(user2), addressOf(user1), 1000, 0)
+	tx.Inputs[0].Sequence = config.SequenceLockTimeIsTime | 2
+	tx.SignTransaction([]util.PrivateKey{user2})
+
+	confirmedMs := chain.GetLatestBlock().GetTimeStampMs()
+	for _, delayMs := range []uint64{1999, 2000} {
+		block := core.CreateNextBlock(chain.Params(), chain.GetLatestBlock(), confirmedMs+delayMs, addressOf(user0), 0, []core.Transaction{*tx})
+		err := chain.AddBlock(block)
+		if delayMs < 2000 && err == nil {
+			t.Error("Accepted a block spending an output before its relative lock time")
+		}
+		if delayMs == 2000 && err != nil {
+			t.Errorf("Failed to add a valid block: %s", err)
+		}
+	}
+}
+
+func TestRelativeTimeLockScript(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	/* Vested to user1 2 blocks after funding */
+	fundTx := fundScript(t, &chain, user0, core.RelativeTimeLockScript(2, addressOf(user1).Hash()), 5000)
+
+	for _, sequence := range []uint32{1, 2} {
+		tx := createSpendingTransaction(fundTx, addressOf(user1), 5000-uint64(sequence))
+		tx.Inputs[0].Sequence = sequence
+		signature, _ := tx.SignatureOf(0, core.SigHashAll, user1)
+		tx.Inputs[0].Script = core.SignatureScript(signature, user1.Public())
+		chain.AcceptBroadcastedTransaction(tx)
+	}
+
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 0 {
+		t.Error("Spent an output before the relative lock time of its script")
+	}
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 4998 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 4998, chain.BalanceOf(addressOf(user1)))
+	}
+}
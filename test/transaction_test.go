@@ -1,8 +1,9 @@
 package test
 
 import (
-	"crypto/rsa"
 	"testing"
+
+	"../util"
 )
 
 func TestTransaction(t *testing.T) {
@@ -13,22 +14,22 @@ func TestTransaction(t *testing.T) {
 	}
 
 	/* Sign the transaction */
-	tran.SignTransaction([]*rsa.PrivateKey{users[0], users[1]})
+	tran.SignTransaction([]util.PrivateKey{users[0], users[1]})
 
 	/* Verify the transaction */
-	if tran.VerifyTransaction([]*rsa.PublicKey{&users[0].PublicKey, &users[1].PublicKey}) != nil {
+	if tran.VerifyTransaction([]util.PublicKey{users[0].Public(), users[1].Public()}) != nil {
 		t.Error("Failed to verify transaction")
 	}
 
 	/* Forge the transaction and verify */
 	tran.Outputs[1].Value = 20000
-	if tran.VerifyTransaction([]*rsa.PublicKey{&users[0].PublicKey, &users[1].PublicKey}) == nil {
+	if tran.VerifyTransaction([]util.PublicKey{users[0].Public(), users[1].Public()}) == nil {
 		t.Error("Verified a forged transaction")
 	}
 
 	tran.Outputs[1].Value = 2000
-	tran.Outputs[2].Address = users[0].PublicKey
-	if tran.VerifyTransaction([]*rsa.PublicKey{&users[0].PublicKey, &users[1].PublicKey}) == nil {
+	tran.Outputs[2].Address = addressOf(users[0])
+	if tran.VerifyTransaction([]util.PublicKey{users[0].Public(), users[1].Public()}) == nil {
 		t.Error("Verified a forged transaction")
 	}
 }
@@ -0,0 +1,178 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"../config"
+	"../core"
+	"../util"
+	"../wallet"
+)
+
+func TestImportWatchOnly(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	watchWallet := wallet.NewMemoryWallet(&config.RegTestParams)
+
+	if err := watchWallet.ImportAddress(addressOf(user0)); err != nil {
+		t.Fatalf("Failed to import an address: %s", err)
+	}
+	address, err := watchWallet.ImportPublicKey(user1.Public())
+	if err != nil || address != addressOf(user1) {
+		t.Fatalf("Failed to import a public key: %v", err)
+	}
+	if pub, exist := watchWallet.PublicKey(address); !exist || !bytes.Equal(pub.Bytes(), user1.Public().Bytes()) {
+		t.Error("Public key of a watch-only address is missing")
+	}
+	if len(watchWallet.WatchOnlyAddresses()) != 2 || len(watchWallet.Addresses()) != 0 {
+		t.Errorf("Watch-only addresses are incorrect: %v", watchWallet.WatchOnlyAddresses())
+	}
+
+	mainNetAddress := util.NewAddress(config.MainNetParams.PubKeyHashAddrID, user1.Public())
+	if err := watchWallet.ImportAddress(mainNetAddress); err == nil {
+		t.Error("Imported an address of another network")
+	}
+
+	/* Watch-only addresses can't sign */
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 10)
+	if err := watchWallet.SignTransaction(tx, &chain); err == nil {
+		t.Error("Signed a transaction with a watch-only address")
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	user2 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+	tx, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 10)
+	confirmTransaction(t, &chain, user0, tx)
+
+	watchWallet := wallet.NewMemoryWallet(&config.RegTestParams)
+	watchWallet.ImportAddress(addressOf(user1))
+	watcher, err := watchWallet.Watch(&chain, 0)
+	if err != nil {
+		t.Fatalf("Failed to watch the chain: %s", err)
+	}
+	outputs := watcher.Outputs()
+	if watcher.Height() != 2 || len(outputs) != 1 || outputs[0].TxID != tx.TxID() || outputs[0].Value != 1000 || outputs[0].Height != 1 {
+		t.Fatalf("Watched outputs are incorrect: height %d, %v", watcher.Height(), outputs)
+	}
+
+	/* Outputs received before the start height are unknown */
+	late, err := watchWallet.Watch(&chain, 2)
+	if err != nil || len(late.Outputs()) != 0 {
+		t.Errorf("Watched outputs from height 2 are incorrect: %v, %v", err, late.Outputs())
+	}
+	if _, err := watchWallet.Watch(&chain, 3); err == nil {
+		t.Error("Watched from a height after the chain")
+	}
+
+	/* New blocks are followed */
+	tx, _ = chain.TransferCoin(addressOf(user1), addressOf(user2), 600, 10)
+	confirmTransaction(t, &chain, user1, tx)
+	if err := watcher.Sync(&chain); err != nil {
+		t.Fatalf("Failed to sync the watcher: %s", err)
+	}
+	if watcher.Height() != 3 || watcher.BalanceOf(addressOf(user1)) != 390 {
+		t.Errorf("Watched balance is incorrect: expected %d, actual %d", 390, watcher.BalanceOf(addressOf(user1)))
+	}
+	history := watcher.History(addressOf(user1))
+	expected := []struct {
+		credit bool
+		value  uint64
+		height uint64
+	}{{true, 1000, 1}, {false, 1000, 2}, {true, 390, 2}}
+	if len(history) != len(expected) {
+		t.Fatalf("History length is incorrect: expected %d, actual %d", len(expected), len(history))
+	}
+	for i, entry := range history {
+		if entry.Credit != expected[i].credit || entry.Value != expected[i].value || entry.Height != expected[i].height || entry.Confirmations != 3-entry.Height {
+			t.Errorf("History entry %d is incorrect: %+v", i, entry)
+		}
+	}
+	if watcher.BalanceOf(addressOf(user2)) != 0 {
+		t.Error("Watcher follows an address out of the wallet")
+	}
+}
+
+func TestWatcherReorg(t *testing.T) {
+	/* Both chains start from the same genesis block */
+	spec, err := core.ParseGenesisSpec([]byte(fmt.Sprintf(`{
+		"chainId": "test-chain",
+		"network": "regtest",
+		"timeStampMs": 1530000000000,
+		"difficulty": {"algorithm": "none"},
+		"allocations": [{"address": %q, "value": 5000}]
+	}`, addressOf(createTestUser(t)).String())))
+	if err != nil {
+		t.Fatalf("Failed to parse genesis spec: %s", err)
+	}
+	chain0, _ := core.InitializeBlockchainFromGenesis(spec)
+	chain1, _ := core.InitializeBlockchainFromGenesis(spec)
+
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	watchWallet := wallet.NewMemoryWallet(&config.RegTestParams)
+	watchWallet.ImportAddress(addressOf(user0))
+	watchWallet.ImportAddress(addressOf(user1))
+
+	chain0.GenerateBlocks(2, addressOf(user0), false)
+	watcher, err := watchWallet.Watch(&chain0, 0)
+	if err != nil || watcher.BalanceOf(addressOf(user0)) != 2*chain0.Params().MinerReward {
+		t.Fatalf("Watched balance is incorrect: %v, %d", err, watcher.BalanceOf(addressOf(user0)))
+	}
+
+	/* The other chain shares the genesis block only, so the mined blocks are replaced */
+	chain1.GenerateBlocks(3, addressOf(user1), false)
+	if err := watcher.Sync(&chain1); err != nil {
+		t.Fatalf("Failed to sync the watcher: %s", err)
+	}
+	if watcher.Height() != 4 || watcher.BalanceOf(addressOf(user0)) != 0 || watcher.BalanceOf(addressOf(user1)) != 3*chain1.Params().MinerReward {
+		t.Errorf("Watched balances after a reorganization are incorrect: %d and %d", watcher.BalanceOf(addressOf(user0)), watcher.BalanceOf(addressOf(user1)))
+	}
+	if len(watcher.History(addressOf(user0))) != 0 {
+		t.Error("History keeps the transactions of replaced blocks")
+	}
+}
+
+func TestWatchOnlyKeystore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	created, err := wallet.CreateWallet(path, "correct horse", &config.RegTestParams)
+	if err != nil {
+		t.Fatalf("Failed to create a wallet: %s", err)
+	}
+	owned, _ := created.NewKey(util.KeyTypeEd25519)
+	if err := created.ImportAddress(owned); err == nil {
+		t.Error("Imported an address the wallet has the key of")
+	}
+
+	/* Addresses are imported without unlocking the wallet */
+	opened, err := wallet.OpenWallet(path, &config.RegTestParams)
+	if err != nil {
+		t.Fatalf("Failed to open a wallet: %s", err)
+	}
+	user := createTestUser(t)
+	if _, err := opened.ImportPublicKey(user.Public()); err != nil {
+		t.Fatalf("Failed to import a public key to a locked wallet: %s", err)
+	}
+
+	reopened, err := wallet.OpenWallet(path, &config.RegTestParams)
+	if err != nil {
+		t.Fatalf("Failed to reopen a wallet: %s", err)
+	}
+	watched := reopened.WatchOnlyAddresses()
+	if len(watched) != 1 || watched[0] != addressOf(user) {
+		t.Fatalf("Reopened watch-only addresses are incorrect: %v", watched)
+	}
+	if pub, exist := reopened.PublicKey(watched[0]); !exist || !bytes.Equal(pub.Bytes(), user.Public().Bytes()) {
+		t.Error("Reopened watch-only public key is incorrect")
+	}
+	if err := reopened.Unlock("correct horse"); err != nil || len(reopened.Addresses()) != 1 || reopened.Addresses()[0] != owned {
+		t.Errorf("Keys of the reopened wallet are incorrect: %v", err)
+	}
+}
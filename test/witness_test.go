@@ -0,0 +1,76 @@
+package test
+
+import (
+	"testing"
+
+	"../core"
+	"../util"
+)
+
+func TestTxIDExcludesWitness(t *testing.T) {
+	users, tran, _ := createTestTransaction()
+	txID := tran.TxID()
+	witnessHash := tran.WitnessHash()
+
+	signInput(t, tran, 0, core.SigHashAll|core.SigHashAnyoneCanPay, users[0])
+	if tran.TxID() != txID {
+		t.Error("Re-signing the transaction changed its id")
+	}
+	if tran.WitnessHash() == witnessHash {
+		t.Error("Re-signing the transaction didn't change its witness hash")
+	}
+
+	tran.Outputs[0].Value++
+	if tran.TxID() == txID {
+		t.Error("Changing an output didn't change the transaction id")
+	}
+}
+
+func TestBlockCommitsToWitness(t *testing.T) {
+	users, tran, _ := createTestTransaction()
+	chain := createRegTestBlockchain(t, 0, addressOf(users[0]))
+
+	block := core.CreateNextBlock(chain.Params(), chain.GetLatestBlock(), chain.GetLatestBlock().GetTimeStampMs()+1, addressOf(users[0]), 0, []core.Transaction{*tran})
+	resignedTx := *tran
+	resignedTx.Inputs = append([]core.TransactionInput{}, tran.Inputs...)
+	signInput(t, &resignedTx, 1, core.SigHashSingle, users[1])
+	resigned := core.CreateNextBlock(chain.Params(), chain.GetLatestBlock(), chain.GetLatestBlock().GetTimeStampMs()+1, addressOf(users[0]), 0, []core.Transaction{resignedTx})
+
+	if block.GetTxRoot() != resigned.GetTxRoot() {
+		t.Error("The transaction root depends on the witness")
+	}
+	if block.GetWitnessRoot() == resigned.GetWitnessRoot() || block.GetBlockHash() == resigned.GetBlockHash() {
+		t.Error("The block doesn't commit to the witness")
+	}
+}
+
+func TestUnconfirmedChainSurvivesResigning(t *testing.T) {
+	user0 := createTestUser(t)
+	user1 := createTestUser(t)
+	user2 := createTestUser(t)
+	chain := createRegTestBlockchain(t, 0, addressOf(user0))
+
+	parent, _ := chain.TransferCoin(addressOf(user0), addressOf(user1), 1000, 0)
+	parent.SignTransaction([]util.PrivateKey{user0})
+
+	/* The child spends the parent before it is confirmed */
+	child := core.CreateTransaction(1, 1)
+	child.Inputs[0].PrevtxMap = parent.TxID()
+	child.Outputs[0].Address = addressOf(user2)
+	child.Outputs[0].Value = 1000
+	child.SignTransaction([]util.PrivateKey{user1})
+
+	/* The parent gets confirmed with another signature */
+	signInput(t, parent, 0, core.SigHashAll|core.SigHashAnyoneCanPay, user0)
+	chain.AcceptBroadcastedTransaction(parent)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user1)) != 1000 {
+		t.Fatalf("User balance is incorrect: expected %d, actual %d", 1000, chain.BalanceOf(addressOf(user1)))
+	}
+
+	chain.AcceptBroadcastedTransaction(&child)
+	chain.GenerateBlocks(1, addressOf(user0), true)
+	if chain.BalanceOf(addressOf(user2)) != 1000 {
+		t.Errorf("User balance is incorrect: expected %d, actual %d", 1000, chain.BalanceOf(addressOf(user2)))
+	}
+}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+//AddressHashSize is the size of the public key hash an address commits to
+const AddressHashSize = 20
+
+//Address is a version byte followed by the hash of a public key.
+//The version tells the network and the kind (public key or script) of the address, so a typo or a
+//wrong network is detected when parsing.
+type Address [1 + AddressHashSize]byte
+
+//Hash20 Hash data to the size of an address (truncated SHA-256)
+func Hash20(data []byte) [AddressHashSize]byte {
+	var hash [AddressHashSize]byte
+	full := sha256.Sum256(data)
+	copy(hash[:], full[:])
+	return hash
+}
+
+//HashPublicKey Hash a serialized public key to the size of an address
+func HashPublicKey(pub PublicKey) [AddressHashSize]byte {
+	return Hash20(pub.Bytes())
+}
+
+//NewAddress Create the address of a public key
+func NewAddress(version byte, pub PublicKey) Address {
+	var address Address
+	address[0] = version
+	hash := HashPublicKey(pub)
+	copy(address[1:], hash[:])
+	return address
+}
+
+//NewScriptAddress Create the address of a locking script
+func NewScriptAddress(version byte, script []byte) Address {
+	var address Address
+	address[0] = version
+	hash := Hash20(script)
+	copy(address[1:], hash[:])
+	return address
+}
+
+//ParseAddress Parse the Base58Check text form of an address
+func ParseAddress(encoded string) (Address, error) {
+	var address Address
+	data, err := Base58CheckDecode(encoded)
+	if err != nil {
+		return address, fmt.Errorf("Invalid address %q: %s", encoded, err)
+	}
+	if len(data) != len(address) {
+		return address, fmt.Errorf("Invalid address %q: wrong length", encoded)
+	}
+	copy(address[:], data)
+	return address, nil
+}
+
+//Version Get the version byte of an address
+func (address Address) Version() byte {
+	return address[0]
+}
+
+//Hash Get the hash an address commits to
+func (address Address) Hash() [AddressHashSize]byte {
+	var hash [AddressHashSize]byte
+	copy(hash[:], address[1:])
+	return hash
+}
+
+//IsZero Check whether the address is unset
+func (address Address) IsZero() bool {
+	return address == Address{}
+}
+
+//String Get the Base58Check text form of an address
+func (address Address) String() string {
+	return Base58CheckEncode(address[:])
+}
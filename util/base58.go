@@ -0,0 +1,82 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Radix = big.NewInt(58)
+
+//Base58Encode Encode bytes with the bitcoin base58 alphabet, leading zeros become '1'
+func Base58Encode(data []byte) string {
+	var num big.Int
+	num.SetBytes(data)
+
+	var encoded []byte
+	var mod big.Int
+	for num.Sign() > 0 {
+		num.DivMod(&num, base58Radix, &mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+//Base58Decode Decode a base58 string
+func Base58Decode(encoded string) ([]byte, error) {
+	var num big.Int
+	for i := 0; i < len(encoded); i++ {
+		digit := bytes.IndexByte([]byte(base58Alphabet), encoded[i])
+		if digit < 0 {
+			return nil, errors.New("Invalid base58 character")
+		}
+		num.Mul(&num, base58Radix)
+		num.Add(&num, big.NewInt(int64(digit)))
+	}
+
+	var zeros int
+	for zeros < len(encoded) && encoded[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), num.Bytes()...), nil
+}
+
+func checksum(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:4]
+}
+
+//Base58CheckEncode Encode bytes followed by the first 4 bytes of their double SHA-256
+func Base58CheckEncode(data []byte) string {
+	return Base58Encode(append(append([]byte{}, data...), checksum(data)...))
+}
+
+//Base58CheckDecode Decode a Base58Check string and verify its checksum
+func Base58CheckDecode(encoded string) ([]byte, error) {
+	decoded, err := Base58Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) < 4 {
+		return nil, errors.New("Base58Check string is too short")
+	}
+	data := decoded[:len(decoded)-4]
+	if !bytes.Equal(checksum(data), decoded[len(decoded)-4:]) {
+		return nil, errors.New("Base58Check checksum mismatch")
+	}
+	return data, nil
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+//Clock is the source of time for chains, miners and the simulator
+type Clock interface {
+	NowMs() uint64 /* epoch in ms */
+	Sleep(d time.Duration)
+}
+
+//RealClock reads the system time
+type RealClock struct {
+}
+
+//NowMs Get the current epoch in ms
+func (RealClock) NowMs() uint64 {
+	return uint64(time.Now().UnixNano() / 1000000)
+}
+
+//Sleep Pause the current goroutine
+func (RealClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+//FakeClock is a clock that only moves when told to, for deterministic tests.
+//Sleeping advances the clock instead of blocking.
+type FakeClock struct {
+	mutex sync.Mutex
+	nowMs uint64
+}
+
+//NewFakeClock Create a fake clock starting at the given epoch in ms
+func NewFakeClock(nowMs uint64) *FakeClock {
+	return &FakeClock{nowMs: nowMs}
+}
+
+//NowMs Get the current epoch in ms
+func (c *FakeClock) NowMs() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.nowMs
+}
+
+//Sleep Advance the clock by d without blocking
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+//Advance Move the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.nowMs += uint64(d / time.Millisecond)
+}
+
+//Set Move the clock to the given epoch in ms
+func (c *FakeClock) Set(nowMs uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.nowMs = nowMs
+}
@@ -1,29 +1,124 @@
 package util
 
 import (
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
+	"errors"
+	"fmt"
 )
 
-func Sign(message []byte, priv *rsa.PrivateKey) ([]byte, error) {
-	rng := rand.Reader
+//KeyType identifies a signature scheme, it is the first byte of a serialized public key
+type KeyType byte
 
-	hashed := sha256.Sum256(message)
+//Supported signature schemes
+const (
+	KeyTypeRSA       KeyType = 1
+	KeyTypeEd25519   KeyType = 2
+	KeyTypeSecp256k1 KeyType = 3
+)
+
+//PublicKey is a serialized public key: the key type followed by the scheme specific encoding.
+//It is a string so it can be compared and used as a map key.
+type PublicKey string
+
+//PrivateKey is a key that can sign messages with its scheme
+type PrivateKey interface {
+	Type() KeyType
+	Public() PublicKey
+	Sign(message []byte) ([]byte, error)
+}
+
+//SignatureScheme creates keys and verifies signatures of one key type
+type SignatureScheme interface {
+	Type() KeyType
+	Name() string
+	GenerateKey() (PrivateKey, error)
+	ParsePublicKey(data []byte) error /* check the scheme specific encoding of a public key */
+	Verify(message []byte, signature []byte, data []byte) error
+	MaxSignatureSize(data []byte) (int, error) /* longest signature of a public key, to estimate transaction sizes */
+}
 
-	signature, err := rsa.SignPKCS1v15(rng, priv, crypto.SHA256, hashed[:])
+var schemes = make(map[KeyType]SignatureScheme)
 
-	return signature, err
+//RegisterScheme Make a signature scheme available to sign and verify
+func RegisterScheme(scheme SignatureScheme) {
+	schemes[scheme.Type()] = scheme
 }
 
-func VerifySignature(message []byte, signature []byte, pub *rsa.PublicKey) error {
-	hashed := sha256.Sum256(message)
+//GetScheme Get the signature scheme of a key type
+func GetScheme(keyType KeyType) (SignatureScheme, error) {
+	scheme, exist := schemes[keyType]
+	if !exist {
+		return nil, fmt.Errorf("Unknown key type %d", keyType)
+	}
+	return scheme, nil
+}
+
+//GenerateKey Generate a private key of the given type
+func GenerateKey(keyType KeyType) (PrivateKey, error) {
+	scheme, err := GetScheme(keyType)
+	if err != nil {
+		return nil, err
+	}
+	return scheme.GenerateKey()
+}
+
+func newPublicKey(keyType KeyType, data []byte) PublicKey {
+	return PublicKey(append([]byte{byte(keyType)}, data...))
+}
+
+//ParsePublicKey Parse and check a serialized public key
+func ParsePublicKey(data []byte) (PublicKey, error) {
+	if len(data) == 0 {
+		return "", errors.New("Empty public key")
+	}
+	scheme, err := GetScheme(KeyType(data[0]))
+	if err != nil {
+		return "", err
+	}
+	if err := scheme.ParsePublicKey(data[1:]); err != nil {
+		return "", err
+	}
+	return PublicKey(data), nil
+}
+
+//Type Get the key type of a public key
+func (pub PublicKey) Type() KeyType {
+	if len(pub) == 0 {
+		return 0
+	}
+	return KeyType(pub[0])
+}
+
+//Bytes Get the serialized public key, including its key type
+func (pub PublicKey) Bytes() []byte {
+	return []byte(pub)
+}
+
+func (pub PublicKey) keyData() []byte {
+	if len(pub) == 0 {
+		return nil
+	}
+	return []byte(pub[1:])
+}
+
+//Sign Sign a message with a private key of any scheme
+func Sign(message []byte, priv PrivateKey) ([]byte, error) {
+	return priv.Sign(message)
+}
 
-	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], signature)
+//MaxSignatureSize Get the length of the longest signature a public key can make
+func MaxSignatureSize(pub PublicKey) (int, error) {
+	scheme, err := GetScheme(pub.Type())
+	if err != nil {
+		return 0, err
+	}
+	return scheme.MaxSignatureSize(pub.keyData())
 }
 
-func GetShortIdentity(address rsa.PublicKey) string {
-	full_identity := address.N.String()
-	return full_identity[len(full_identity)-5:]
+//VerifySignature Verify a signature with the scheme of the public key
+func VerifySignature(message []byte, signature []byte, pub PublicKey) error {
+	scheme, err := GetScheme(pub.Type())
+	if err != nil {
+		return err
+	}
+	return scheme.Verify(message, signature, pub.keyData())
 }
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+)
+
+type ed25519Scheme struct {
+}
+
+type ed25519PrivateKey struct {
+	key ed25519.PrivateKey
+}
+
+func init() {
+	RegisterScheme(ed25519Scheme{})
+}
+
+//NewEd25519PrivateKey Wrap an Ed25519 key
+func NewEd25519PrivateKey(key ed25519.PrivateKey) PrivateKey {
+	return ed25519PrivateKey{key}
+}
+
+func (ed25519Scheme) Type() KeyType {
+	return KeyTypeEd25519
+}
+
+func (ed25519Scheme) Name() string {
+	return "ed25519"
+}
+
+func (ed25519Scheme) GenerateKey() (PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewEd25519PrivateKey(key), nil
+}
+
+func (ed25519Scheme) ParsePublicKey(data []byte) error {
+	if len(data) != ed25519.PublicKeySize {
+		return errors.New("Invalid Ed25519 public key size")
+	}
+	return nil
+}
+
+func (s ed25519Scheme) Verify(message []byte, signature []byte, data []byte) error {
+	if err := s.ParsePublicKey(data); err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(data), message, signature) {
+		return errors.New("Ed25519 verification error")
+	}
+	return nil
+}
+
+func (ed25519Scheme) MaxSignatureSize(data []byte) (int, error) {
+	return ed25519.SignatureSize, nil
+}
+
+func (priv ed25519PrivateKey) Type() KeyType {
+	return KeyTypeEd25519
+}
+
+func (priv ed25519PrivateKey) Public() PublicKey {
+	return newPublicKey(KeyTypeEd25519, priv.key.Public().(ed25519.PublicKey))
+}
+
+func (priv ed25519PrivateKey) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(priv.key, message), nil
+}
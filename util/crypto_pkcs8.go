@@ -0,0 +1,107 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+const pemPrivateKeyType = "PRIVATE KEY"
+
+var (
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSecp256k1      = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+/*
+ * PKCS#8 structures for secp256k1 keys, which the x509 package doesn't support
+ */
+type pkcs8PrivateKey struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+type ecPrivateKey struct {
+	Version    int
+	PrivateKey []byte
+}
+
+//MarshalPKCS8PrivateKey Encode a private key of any scheme in PKCS#8 (DER)
+func MarshalPKCS8PrivateKey(priv PrivateKey) ([]byte, error) {
+	switch key := priv.(type) {
+	case rsaPrivateKey:
+		return x509.MarshalPKCS8PrivateKey(key.key)
+	case ed25519PrivateKey:
+		return x509.MarshalPKCS8PrivateKey(key.key)
+	case secp256k1PrivateKey:
+		inner, err := asn1.Marshal(ecPrivateKey{Version: 1, PrivateKey: key.key.Serialize()})
+		if err != nil {
+			return nil, err
+		}
+		params, err := asn1.Marshal(oidSecp256k1)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(pkcs8PrivateKey{
+			Algo:       pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: params}},
+			PrivateKey: inner,
+		})
+	}
+	return nil, fmt.Errorf("Cannot encode a private key of type %d", priv.Type())
+}
+
+//ParsePKCS8PrivateKey Decode a private key encoded in PKCS#8 (DER)
+func ParsePKCS8PrivateKey(der []byte) (PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return NewRSAPrivateKey(key), nil
+		case ed25519.PrivateKey:
+			return NewEd25519PrivateKey(key), nil
+		}
+		return nil, errors.New("Unsupported private key algorithm")
+	}
+
+	var outer pkcs8PrivateKey
+	if rest, err := asn1.Unmarshal(der, &outer); err != nil || len(rest) != 0 {
+		return nil, errors.New("Invalid PKCS#8 private key")
+	}
+	var curve asn1.ObjectIdentifier
+	if !outer.Algo.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, errors.New("Unsupported private key algorithm")
+	}
+	if _, err := asn1.Unmarshal(outer.Algo.Parameters.FullBytes, &curve); err != nil || !curve.Equal(oidSecp256k1) {
+		return nil, errors.New("Unsupported elliptic curve")
+	}
+	var inner ecPrivateKey
+	if _, err := asn1.Unmarshal(outer.PrivateKey, &inner); err != nil || len(inner.PrivateKey) != secp256k1.PrivKeyBytesLen {
+		return nil, errors.New("Invalid secp256k1 private key")
+	}
+	return NewSecp256k1PrivateKey(secp256k1.PrivKeyFromBytes(inner.PrivateKey)), nil
+}
+
+//EncodePrivateKeyPEM Encode a private key in a PEM "PRIVATE KEY" block (PKCS#8)
+func EncodePrivateKeyPEM(priv PrivateKey) ([]byte, error) {
+	der, err := MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKeyType, Bytes: der}), nil
+}
+
+//DecodePrivateKeyPEM Decode a private key from a PEM "PRIVATE KEY" block (PKCS#8)
+func DecodePrivateKeyPEM(data []byte) (PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemPrivateKeyType {
+		return nil, errors.New("No PEM private key block found")
+	}
+	return ParsePKCS8PrivateKey(block.Bytes)
+}
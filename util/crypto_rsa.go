@@ -0,0 +1,83 @@
+package util
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+)
+
+const rsaKeyBits = 1024
+
+type rsaScheme struct {
+}
+
+type rsaPrivateKey struct {
+	key *rsa.PrivateKey
+}
+
+func init() {
+	RegisterScheme(rsaScheme{})
+}
+
+//NewRSAPrivateKey Wrap an RSA key, signatures are PKCS#1 v1.5 over SHA-256
+func NewRSAPrivateKey(key *rsa.PrivateKey) PrivateKey {
+	return rsaPrivateKey{key}
+}
+
+//NewRSAPublicKey Serialize an RSA public key
+func NewRSAPublicKey(key *rsa.PublicKey) PublicKey {
+	return newPublicKey(KeyTypeRSA, x509.MarshalPKCS1PublicKey(key))
+}
+
+func (rsaScheme) Type() KeyType {
+	return KeyTypeRSA
+}
+
+func (rsaScheme) Name() string {
+	return "rsa"
+}
+
+func (rsaScheme) GenerateKey() (PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	return NewRSAPrivateKey(key), nil
+}
+
+func (rsaScheme) ParsePublicKey(data []byte) error {
+	_, err := x509.ParsePKCS1PublicKey(data)
+	return err
+}
+
+func (rsaScheme) Verify(message []byte, signature []byte, data []byte) error {
+	pub, err := x509.ParsePKCS1PublicKey(data)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256(message)
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], signature)
+}
+
+func (rsaScheme) MaxSignatureSize(data []byte) (int, error) {
+	pub, err := x509.ParsePKCS1PublicKey(data)
+	if err != nil {
+		return 0, err
+	}
+	return pub.Size(), nil
+}
+
+func (priv rsaPrivateKey) Type() KeyType {
+	return KeyTypeRSA
+}
+
+func (priv rsaPrivateKey) Public() PublicKey {
+	return NewRSAPublicKey(&priv.key.PublicKey)
+}
+
+func (priv rsaPrivateKey) Sign(message []byte) ([]byte, error) {
+	hashed := sha256.Sum256(message)
+	return rsa.SignPKCS1v15(rand.Reader, priv.key, crypto.SHA256, hashed[:])
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+//DER encoding of two 33 bytes integers
+const secp256k1MaxSignatureSize = 72
+
+type secp256k1Scheme struct {
+}
+
+type secp256k1PrivateKey struct {
+	key *secp256k1.PrivateKey
+}
+
+func init() {
+	RegisterScheme(secp256k1Scheme{})
+}
+
+//NewSecp256k1PrivateKey Wrap a secp256k1 key, signatures are DER encoded ECDSA over SHA-256
+func NewSecp256k1PrivateKey(key *secp256k1.PrivateKey) PrivateKey {
+	return secp256k1PrivateKey{key}
+}
+
+func (secp256k1Scheme) Type() KeyType {
+	return KeyTypeSecp256k1
+}
+
+func (secp256k1Scheme) Name() string {
+	return "secp256k1"
+}
+
+func (secp256k1Scheme) GenerateKey() (PrivateKey, error) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewSecp256k1PrivateKey(key), nil
+}
+
+func (secp256k1Scheme) ParsePublicKey(data []byte) error {
+	_, err := secp256k1.ParsePubKey(data)
+	return err
+}
+
+func (secp256k1Scheme) Verify(message []byte, signature []byte, data []byte) error {
+	pub, err := secp256k1.ParsePubKey(data)
+	if err != nil {
+		return err
+	}
+	sig, err := ecdsa.ParseDERSignature(signature)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256(message)
+	if !sig.Verify(hashed[:], pub) {
+		return errors.New("secp256k1 verification error")
+	}
+	return nil
+}
+
+func (secp256k1Scheme) MaxSignatureSize(data []byte) (int, error) {
+	return secp256k1MaxSignatureSize, nil
+}
+
+func (priv secp256k1PrivateKey) Type() KeyType {
+	return KeyTypeSecp256k1
+}
+
+func (priv secp256k1PrivateKey) Public() PublicKey {
+	return newPublicKey(KeyTypeSecp256k1, priv.key.PubKey().SerializeCompressed())
+}
+
+func (priv secp256k1PrivateKey) Sign(message []byte) ([]byte, error) {
+	hashed := sha256.Sum256(message)
+	return ecdsa.Sign(priv.key, hashed[:]).Serialize(), nil
+}
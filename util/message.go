@@ -0,0 +1,54 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+ * Prepended to every signed message, so a message signature never verifies as a transaction signature.
+ * The signed data of a transaction input has its signature hash type at byte 4, where this prefix has 'i',
+ * which is no hash type.
+ */
+const messagePrefix = "\x20Mini-Blockchain Signed Message:\n"
+
+func messageData(message []byte) []byte {
+	return append([]byte(messagePrefix), message...)
+}
+
+//SignMessage Sign a message with a key, e.g. to prove the ownership of its address.
+//The signature is in base64 and carries the public key, so it's verified with the address only.
+func SignMessage(message []byte, priv PrivateKey) (string, error) {
+	signature, err := Sign(messageData(message), priv)
+	if err != nil {
+		return "", err
+	}
+	pub := priv.Public().Bytes()
+	data := make([]byte, 2, 2+len(pub)+len(signature))
+	binary.BigEndian.PutUint16(data, uint16(len(pub)))
+	data = append(data, pub...)
+	data = append(data, signature...)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+//VerifyMessage Verify a signature of a message made by SignMessage with the key of a public key address
+func VerifyMessage(address Address, signature string, message []byte) error {
+	data, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("Invalid message signature: %s", err)
+	}
+	if len(data) < 2 || len(data) < 2+int(binary.BigEndian.Uint16(data)) {
+		return errors.New("Invalid message signature: too short")
+	}
+	size := 2 + int(binary.BigEndian.Uint16(data))
+	pub, err := ParsePublicKey(data[2:size])
+	if err != nil {
+		return fmt.Errorf("Invalid message signature: %s", err)
+	}
+	if NewAddress(address.Version(), pub) != address {
+		return fmt.Errorf("Message was not signed by the key of %s", address)
+	}
+	return VerifySignature(messageData(message), data[size:], pub)
+}
@@ -0,0 +1,260 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/tyler-smith/go-bip39"
+
+	"../core"
+	"../util"
+)
+
+//Derivation branches of an HD wallet: addresses given to payers, and addresses receiving the change
+const (
+	BranchReceiving uint32 = 0
+	BranchChange    uint32 = 1
+)
+
+//HDGapLimit consecutive unused addresses after which a rescan stops looking further in a branch
+const HDGapLimit = 20
+
+//MnemonicEntropyBits the entropy of new mnemonics, 128 bits make 12 words
+const MnemonicEntropyBits = 128
+
+const (
+	hardened  uint32 = 0x80000000
+	hdPurpose uint32 = 44
+)
+
+/*
+ * A private key with its chain code. Only hardened derivation is used, which SLIP-0010 defines
+ * for both secp256k1 (it matches BIP-32) and Ed25519. RSA keys can't be derived.
+ */
+type extendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+//NewMnemonic Generate a random seed encoded as BIP-39 words, the last word carries a checksum
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(MnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+/*
+ * Check the checksum of the mnemonic and stretch it into the seed
+ */
+func mnemonicToSeed(mnemonic string) ([]byte, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid mnemonic: %s", err)
+	}
+	return seed, nil
+}
+
+func masterKey(seed []byte, keyType util.KeyType) (extendedKey, error) {
+	var curve string
+	switch keyType {
+	case util.KeyTypeSecp256k1:
+		curve = "Bitcoin seed"
+	case util.KeyTypeEd25519:
+		curve = "ed25519 seed"
+	default:
+		return extendedKey{}, fmt.Errorf("Keys of type %d can't be derived", keyType)
+	}
+
+	mac := hmac.New(sha512.New, []byte(curve))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	master := extendedKey{key: sum[:32], chainCode: sum[32:]}
+	if keyType == util.KeyTypeSecp256k1 && !isValidScalar(master.key) {
+		return extendedKey{}, errors.New("Seed makes an invalid master key")
+	}
+	return master, nil
+}
+
+func isValidScalar(data []byte) bool {
+	var scalar secp256k1.ModNScalar
+	overflow := scalar.SetByteSlice(data)
+	return !overflow && !scalar.IsZero()
+}
+
+/*
+ * Derive the hardened child index of the key
+ */
+func (parent extendedKey) child(index uint32, keyType util.KeyType) (extendedKey, error) {
+	data := append([]byte{0}, parent.key...)
+	data = binary.BigEndian.AppendUint32(data, index|hardened)
+	mac := hmac.New(sha512.New, parent.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	child := extendedKey{key: sum[:32], chainCode: sum[32:]}
+
+	if keyType == util.KeyTypeSecp256k1 {
+		/* BIP-32: the child key is the tweak added to the parent key */
+		if !isValidScalar(child.key) {
+			return extendedKey{}, fmt.Errorf("Index %d derives an invalid key", index)
+		}
+		var tweak, key secp256k1.ModNScalar
+		tweak.SetByteSlice(child.key)
+		key.SetByteSlice(parent.key)
+		key.Add(&tweak)
+		if key.IsZero() {
+			return extendedKey{}, fmt.Errorf("Index %d derives an invalid key", index)
+		}
+		serialized := key.Bytes()
+		child.key = serialized[:]
+	}
+	return child, nil
+}
+
+/*
+ * Derive the key m/44'/<key type>'/0'/<branch>'/<index>'
+ */
+func deriveKey(seed []byte, keyType util.KeyType, branch uint32, index uint32) (util.PrivateKey, error) {
+	key, err := masterKey(seed, keyType)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range []uint32{hdPurpose, uint32(keyType), 0, branch, index} {
+		if key, err = key.child(step, keyType); err != nil {
+			return nil, err
+		}
+	}
+
+	if keyType == util.KeyTypeSecp256k1 {
+		return util.NewSecp256k1PrivateKey(secp256k1.PrivKeyFromBytes(key.key)), nil
+	}
+	return util.NewEd25519PrivateKey(ed25519.NewKeyFromSeed(key.key)), nil
+}
+
+//IsHD Check whether the keys of the wallet are derived from a seed
+func (wallet *Wallet) IsHD() bool {
+	return wallet.hdKeyType != 0
+}
+
+//SetMnemonic Make an empty wallet derive its keys of keyType from the seed encoded by mnemonic
+func (wallet *Wallet) SetMnemonic(mnemonic string, keyType util.KeyType) error {
+	if wallet.IsLocked() {
+		return errors.New("Wallet is locked")
+	}
+	if len(wallet.addresses) != 0 || wallet.IsHD() {
+		return errors.New("Only an empty wallet can be given a seed")
+	}
+	seed, err := mnemonicToSeed(mnemonic)
+	if err != nil {
+		return err
+	}
+	if _, err := masterKey(seed, keyType); err != nil {
+		return err
+	}
+
+	wallet.seed = seed
+	wallet.hdKeyType = keyType
+	wallet.hdNext = [2]uint32{}
+	if err := wallet.Save(); err != nil {
+		wallet.seed = nil
+		wallet.hdKeyType = 0
+		return err
+	}
+	return nil
+}
+
+//NewReceivingAddress Derive the next address to give to payers
+func (wallet *Wallet) NewReceivingAddress() (util.Address, error) {
+	return wallet.deriveNext(BranchReceiving)
+}
+
+//NewChangeAddress Get a new address to receive the change of a transfer: the next one of the change branch
+//of an HD wallet, a new key of the type of the first key otherwise
+func (wallet *Wallet) NewChangeAddress() (util.Address, error) {
+	if wallet.IsHD() || len(wallet.addresses) == 0 {
+		return wallet.deriveNext(BranchChange)
+	}
+	return wallet.NewKey(wallet.publics[wallet.addresses[0]].Type())
+}
+
+func (wallet *Wallet) deriveNext(branch uint32) (util.Address, error) {
+	if !wallet.IsHD() {
+		return util.Address{}, errors.New("Wallet has no seed")
+	}
+	if wallet.IsLocked() {
+		return util.Address{}, errors.New("Wallet is locked")
+	}
+	key, err := deriveKey(wallet.seed, wallet.hdKeyType, branch, wallet.hdNext[branch])
+	if err != nil {
+		return util.Address{}, err
+	}
+	wallet.hdNext[branch]++
+	return wallet.ImportKey(key)
+}
+
+//Rescan Derive the keys of both branches until HDGapLimit consecutive addresses were never used on the chain,
+//so the coins of a recovered wallet are found. Return the number of addresses added.
+func (wallet *Wallet) Rescan(chain *core.Blockchain) (int, error) {
+	if !wallet.IsHD() {
+		return 0, errors.New("Wallet has no seed")
+	}
+	if wallet.IsLocked() {
+		return 0, errors.New("Wallet is locked")
+	}
+
+	added := 0
+	for _, branch := range []uint32{BranchReceiving, BranchChange} {
+		for index, gap := uint32(0), 0; gap < HDGapLimit; index++ {
+			key, err := deriveKey(wallet.seed, wallet.hdKeyType, branch, index)
+			if err != nil {
+				return added, err
+			}
+			address := util.NewAddress(wallet.params.PubKeyHashAddrID, key.Public())
+			if _, used := chain.AddressMap[address]; !used {
+				gap++
+				continue
+			}
+			gap = 0
+
+			if _, exist := wallet.publics[address]; !exist {
+				if _, err := wallet.ImportKey(key); err != nil {
+					return added, err
+				}
+				added++
+			}
+			if index >= wallet.hdNext[branch] {
+				wallet.hdNext[branch] = index + 1
+			}
+		}
+	}
+	return added, wallet.Save()
+}
+
+//RecoverWallet Create a wallet from a mnemonic in a new keystore, and rescan the chain for its addresses
+func RecoverWallet(path string, passphrase string, mnemonic string, keyType util.KeyType, chain *core.Blockchain) (*Wallet, error) {
+	if _, err := mnemonicToSeed(mnemonic); err != nil {
+		return nil, err
+	}
+
+	var wallet *Wallet
+	var err error
+	if path == "" {
+		wallet = NewMemoryWallet(chain.Params())
+	} else if wallet, err = CreateWallet(path, passphrase, chain.Params()); err != nil {
+		return nil, err
+	}
+
+	if err := wallet.SetMnemonic(mnemonic, keyType); err != nil {
+		return nil, err
+	}
+	if _, err := wallet.Rescan(chain); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
@@ -0,0 +1,323 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+
+	"../config"
+	"../util"
+)
+
+/*
+ * Version 1 keystores only seal the list of keys
+ */
+const keystoreVersion = 2
+
+//Cost of the scrypt key derivation of new keystores, see golang.org/x/crypto/scrypt
+const (
+	ScryptN = 1 << 15
+	ScryptR = 8
+	ScryptP = 1
+)
+
+const (
+	kdfScrypt     = "scrypt"
+	saltSize      = 16
+	encryptionKey = 32 /* AES-256 */
+)
+
+type kdfParams struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+/*
+ * The keystore file is JSON. The public keys are in clear so a locked wallet knows its addresses,
+ * the secrets are sealed by AES-256-GCM with a key derived from the passphrase.
+ */
+type keystoreFile struct {
+	Version    int          `json:"version"`
+	Network    string       `json:"network"`
+	KDF        kdfParams    `json:"kdf"`
+	PublicKeys [][]byte     `json:"publicKeys"`
+	HDKeyType  util.KeyType `json:"hdKeyType,omitempty"`
+	HDNext     [2]uint32    `json:"hdNext"`
+	WatchOnly  []watched    `json:"watchOnly,omitempty"`
+	Nonce      []byte       `json:"nonce"`
+	Ciphertext []byte       `json:"ciphertext"`
+}
+
+/*
+ * A watch-only address, with its public key if it was imported
+ */
+type watched struct {
+	Address   string `json:"address"`
+	PublicKey []byte `json:"publicKey,omitempty"`
+}
+
+/*
+ * The sealed secrets: the PKCS#8 keys and the HD seed
+ */
+type keystoreSecrets struct {
+	Keys [][]byte `json:"keys"`
+	Seed []byte   `json:"seed,omitempty"`
+}
+
+type keystore struct {
+	file   keystoreFile
+	secret []byte /* derived from the passphrase, nil while locked */
+}
+
+//CreateWallet Create an empty unlocked wallet saved in a new keystore file encrypted by passphrase
+func CreateWallet(path string, passphrase string, params *config.ChainParams) (*Wallet, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("Keystore %s already exists", path)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	store := &keystore{file: keystoreFile{
+		Version: keystoreVersion,
+		Network: params.Name,
+		KDF:     kdfParams{Name: kdfScrypt, Salt: salt, N: ScryptN, R: ScryptR, P: ScryptP},
+	}}
+	secret, err := store.deriveSecret(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	store.secret = secret
+
+	wallet := NewMemoryWallet(params)
+	wallet.path = path
+	wallet.store = store
+	if err := wallet.Save(); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+//OpenWallet Load a wallet from a keystore file, it is locked until Unlock
+func OpenWallet(path string, params *config.ChainParams) (*Wallet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Invalid keystore %s: %s", path, err)
+	}
+	if file.Version < 1 || file.Version > keystoreVersion {
+		return nil, fmt.Errorf("Unsupported keystore version %d", file.Version)
+	}
+	if file.Network != params.Name {
+		return nil, fmt.Errorf("Keystore belongs to %s, not %s", file.Network, params.Name)
+	}
+
+	wallet := NewMemoryWallet(params)
+	wallet.path = path
+	wallet.store = &keystore{file: file}
+	wallet.keys = nil
+	wallet.hdKeyType = file.HDKeyType
+	wallet.hdNext = file.HDNext
+	for _, data := range file.PublicKeys {
+		pub, err := util.ParsePublicKey(data)
+		if err != nil {
+			return nil, err
+		}
+		address := util.NewAddress(params.PubKeyHashAddrID, pub)
+		wallet.addresses = append(wallet.addresses, address)
+		wallet.publics[address] = pub
+	}
+	for _, entry := range file.WatchOnly {
+		address, err := util.ParseAddress(entry.Address)
+		if err != nil {
+			return nil, err
+		}
+		if len(entry.PublicKey) != 0 {
+			pub, err := util.ParsePublicKey(entry.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			if util.NewAddress(params.PubKeyHashAddrID, pub) != address {
+				return nil, fmt.Errorf("Watched public key doesn't match %s", entry.Address)
+			}
+			wallet.publics[address] = pub
+		}
+		wallet.watchOnly = append(wallet.watchOnly, address)
+	}
+	return wallet, nil
+}
+
+//Unlock Decrypt the private keys with the passphrase
+func (wallet *Wallet) Unlock(passphrase string) error {
+	if !wallet.IsLocked() {
+		return nil
+	}
+	store := wallet.store
+	secret, err := store.deriveSecret(passphrase)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return err
+	}
+	plaintext, err := aead.Open(nil, store.file.Nonce, store.file.Ciphertext, nil)
+	if err != nil {
+		return errors.New("Wrong passphrase or corrupted keystore")
+	}
+
+	var secrets keystoreSecrets
+	if store.file.Version == 1 {
+		err = json.Unmarshal(plaintext, &secrets.Keys)
+	} else {
+		err = json.Unmarshal(plaintext, &secrets)
+	}
+	if err != nil {
+		return err
+	}
+	if wallet.IsHD() && len(secrets.Seed) == 0 {
+		return errors.New("Keystore of an HD wallet has no seed")
+	}
+	keys := make(map[util.Address]util.PrivateKey)
+	for _, der := range secrets.Keys {
+		key, err := util.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return err
+		}
+		keys[util.NewAddress(wallet.params.PubKeyHashAddrID, key.Public())] = key
+	}
+	/* The public keys in clear must be those of the sealed keys */
+	if len(keys) != len(wallet.addresses) {
+		return errors.New("Keystore public keys don't match its private keys")
+	}
+	for _, address := range wallet.addresses {
+		if key, exist := keys[address]; !exist || key.Public() != wallet.publics[address] {
+			return errors.New("Keystore public keys don't match its private keys")
+		}
+	}
+
+	wallet.keys = keys
+	wallet.seed = secrets.Seed
+	store.secret = secret
+	return nil
+}
+
+//Lock Forget the private keys until the next Unlock
+func (wallet *Wallet) Lock() error {
+	if wallet.store == nil {
+		return errors.New("Wallet without keystore can't be locked")
+	}
+	for i := range wallet.store.secret {
+		wallet.store.secret[i] = 0
+	}
+	wallet.store.secret = nil
+	for i := range wallet.seed {
+		wallet.seed[i] = 0
+	}
+	wallet.seed = nil
+	wallet.keys = nil
+	return nil
+}
+
+//Save Write the wallet to the keystore file. The keys can't change while the wallet is locked,
+//so a locked wallet only saves its watch-only addresses. Wallets without keystore are not saved.
+func (wallet *Wallet) Save() error {
+	if wallet.store == nil {
+		return nil
+	}
+
+	file := wallet.store.file
+	file.WatchOnly = nil
+	for _, address := range wallet.watchOnly {
+		entry := watched{Address: address.String()}
+		if pub, exist := wallet.publics[address]; exist {
+			entry.PublicKey = pub.Bytes()
+		}
+		file.WatchOnly = append(file.WatchOnly, entry)
+	}
+
+	if !wallet.IsLocked() {
+		if err := wallet.seal(&file); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(&file, "", "  ")
+	if err != nil {
+		return err
+	}
+	/* Replace the file at once so a crash never leaves a truncated keystore */
+	tmpPath := wallet.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, wallet.path); err != nil {
+		return err
+	}
+	wallet.store.file = file
+	return nil
+}
+
+/*
+ * Encrypt the keys and the seed of an unlocked wallet into the keystore file
+ */
+func (wallet *Wallet) seal(file *keystoreFile) error {
+	file.Version = keystoreVersion
+	file.PublicKeys = nil
+	file.HDKeyType = wallet.hdKeyType
+	file.HDNext = wallet.hdNext
+	secrets := keystoreSecrets{Seed: wallet.seed}
+	for _, address := range wallet.addresses {
+		der, err := util.MarshalPKCS8PrivateKey(wallet.keys[address])
+		if err != nil {
+			return err
+		}
+		secrets.Keys = append(secrets.Keys, der)
+		file.PublicKeys = append(file.PublicKeys, wallet.publics[address].Bytes())
+	}
+	plaintext, err := json.Marshal(&secrets)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(wallet.store.secret)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+	return nil
+}
+
+func (store *keystore) deriveSecret(passphrase string) ([]byte, error) {
+	kdf := store.file.KDF
+	if kdf.Name != kdfScrypt {
+		return nil, fmt.Errorf("Unsupported key derivation %q", kdf.Name)
+	}
+	return scrypt.Key([]byte(passphrase), kdf.Salt, kdf.N, kdf.R, kdf.P, encryptionKey)
+}
+
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"errors"
+
+	"../core"
+	"../util"
+)
+
+//IsMine Check whether the wallet has the key of an address, watch-only addresses are not its own
+func (wallet *Wallet) IsMine(address util.Address) bool {
+	_, exist := wallet.publics[address]
+	return exist && !wallet.isWatched(address)
+}
+
+//Balance Get the balance of native coins of all the keys of the wallet
+func (wallet *Wallet) Balance(chain *core.Blockchain) uint64 {
+	var balance uint64
+	for _, address := range wallet.addresses {
+		/* The addresses never paid are unknown to the chain */
+		if _, exist := chain.AddressMap[address]; exist {
+			balance += chain.BalanceOf(address)
+		}
+	}
+	return balance
+}
+
+//SpendableBalance Get the native coins of all the keys of the wallet that can be spent in the next block
+func (wallet *Wallet) SpendableBalance(chain *core.Blockchain) uint64 {
+	return chain.SpendableBalanceFrom(wallet.Addresses())
+}
+
+//TransferCoin Make a signed transaction paying amount to an address from the coins of all the keys of the wallet.
+//The change goes to a new address of the wallet, so a payment doesn't link the addresses to the ones paid later.
+func (wallet *Wallet) TransferCoin(chain *core.Blockchain, to util.Address, amount uint64, fee uint64) (*core.Transaction, error) {
+	if wallet.IsLocked() {
+		return nil, errors.New("Wallet is locked")
+	}
+	tran, _, err := chain.TransferCoinFrom(wallet.Addresses(), to, amount, fee, wallet.NewChangeAddress, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := wallet.SignTransaction(tran, chain); err != nil {
+		return nil, err
+	}
+	return tran, nil
+}
+
+//NewPayment Start a payment to several recipients from the coins of all the keys of the wallet,
+//its change goes to a new address of the wallet like the one of TransferCoin. Sign it with SignTransaction.
+func (wallet *Wallet) NewPayment(chain *core.Blockchain, feeRate uint64) (*core.Payment, error) {
+	if wallet.IsLocked() {
+		return nil, errors.New("Wallet is locked")
+	}
+	if len(wallet.addresses) == 0 {
+		return nil, errors.New("Wallet has no key")
+	}
+	var signers []util.PublicKey
+	for _, address := range wallet.addresses {
+		signers = append(signers, wallet.publics[address])
+	}
+	payment := chain.NewPaymentFrom(signers, feeRate)
+	payment.ChangeAddress = wallet.NewChangeAddress
+	return payment, nil
+}
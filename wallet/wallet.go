@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+//Wallet manages the keys of a user. A wallet backed by a keystore file keeps the keys encrypted
+//on disk and only holds them in memory while it is unlocked.
+type Wallet struct {
+	params    *config.ChainParams
+	addresses []util.Address /* in the order the keys were added */
+	publics   map[util.Address]util.PublicKey
+	keys      map[util.Address]util.PrivateKey /* nil while locked */
+	watchOnly []util.Address                   /* addresses watched without their keys */
+
+	seed      []byte       /* HD seed, nil while locked or if the keys are not derived */
+	hdKeyType util.KeyType /* type of the derived keys, 0 if the keys are not derived */
+	hdNext    [2]uint32    /* next index to derive in each branch */
+
+	path  string /* keystore file, empty for a wallet only living in memory */
+	store *keystore
+}
+
+//NewMemoryWallet Create an unlocked wallet which is never saved, e.g. for simulated users
+func NewMemoryWallet(params *config.ChainParams) *Wallet {
+	return &Wallet{
+		params:  params,
+		publics: make(map[util.Address]util.PublicKey),
+		keys:    make(map[util.Address]util.PrivateKey),
+	}
+}
+
+//IsLocked Check whether the private keys are unavailable
+func (wallet *Wallet) IsLocked() bool {
+	return wallet.keys == nil
+}
+
+//Addresses Get the addresses of all the keys, in the order they were added
+func (wallet *Wallet) Addresses() []util.Address {
+	return append([]util.Address{}, wallet.addresses...)
+}
+
+//PublicKey Get the public key of an address of the wallet
+func (wallet *Wallet) PublicKey(address util.Address) (util.PublicKey, bool) {
+	pub, exist := wallet.publics[address]
+	return pub, exist
+}
+
+//Key Get the private key of an address, the wallet must be unlocked
+func (wallet *Wallet) Key(address util.Address) (util.PrivateKey, error) {
+	if wallet.IsLocked() {
+		return nil, errors.New("Wallet is locked")
+	}
+	key, exist := wallet.keys[address]
+	if !exist {
+		return nil, fmt.Errorf("Wallet has no key of %s", address)
+	}
+	return key, nil
+}
+
+//NewKey Generate a key of the given type and add it to the wallet
+func (wallet *Wallet) NewKey(keyType util.KeyType) (util.Address, error) {
+	key, err := util.GenerateKey(keyType)
+	if err != nil {
+		return util.Address{}, err
+	}
+	return wallet.ImportKey(key)
+}
+
+//ImportKey Add a private key to the wallet, the wallet must be unlocked
+func (wallet *Wallet) ImportKey(key util.PrivateKey) (util.Address, error) {
+	if wallet.IsLocked() {
+		return util.Address{}, errors.New("Wallet is locked")
+	}
+	address := util.NewAddress(wallet.params.PubKeyHashAddrID, key.Public())
+	if _, exist := wallet.keys[address]; exist {
+		return address, nil
+	}
+
+	wallet.addresses = append(wallet.addresses, address)
+	wallet.publics[address] = key.Public()
+	wallet.keys[address] = key
+	if err := wallet.Save(); err != nil {
+		wallet.removeKey(address)
+		return util.Address{}, err
+	}
+	return address, nil
+}
+
+//ImportPEM Add a private key encoded in a PEM "PRIVATE KEY" block (PKCS#8)
+func (wallet *Wallet) ImportPEM(data []byte) (util.Address, error) {
+	key, err := util.DecodePrivateKeyPEM(data)
+	if err != nil {
+		return util.Address{}, err
+	}
+	return wallet.ImportKey(key)
+}
+
+//ExportPEM Encode the private key of an address in a PEM "PRIVATE KEY" block (PKCS#8)
+func (wallet *Wallet) ExportPEM(address util.Address) ([]byte, error) {
+	key, err := wallet.Key(address)
+	if err != nil {
+		return nil, err
+	}
+	return util.EncodePrivateKeyPEM(key)
+}
+
+//SignTransaction Sign every input of a transaction spending a pay to public key hash output of the wallet
+func (wallet *Wallet) SignTransaction(tran *core.Transaction, chain *core.Blockchain) error {
+	for i := range tran.Inputs {
+		output, exist := chain.OutputOf(&tran.Inputs[i])
+		if !exist {
+			return fmt.Errorf("Cannot find the output spent by input %d", i)
+		}
+		key, err := wallet.Key(output.Address)
+		if err != nil {
+			return err
+		}
+		signature, err := tran.SignatureOf(i, core.SigHashAll, key)
+		if err != nil {
+			return err
+		}
+		tran.Inputs[i].Script = core.SignatureScript(signature, key.Public())
+	}
+	return nil
+}
+
+//SignMessage Sign a message with the key of an address, to prove the wallet owns it without moving coins.
+//Anyone can check the signature with util.VerifyMessage.
+func (wallet *Wallet) SignMessage(address util.Address, message []byte) (string, error) {
+	key, err := wallet.Key(address)
+	if err != nil {
+		return "", err
+	}
+	return util.SignMessage(message, key)
+}
+
+//SignPartialTransaction Add the signatures of the keys of the wallet to every input of a partial transaction
+//they can sign, return the number of signatures added. The outputs spent come with the partial transaction,
+//so it works on a wallet without the chain, e.g. an offline one.
+func (wallet *Wallet) SignPartialTransaction(partial *core.PartialTransaction) (int, error) {
+	if wallet.IsLocked() {
+		return 0, errors.New("Wallet is locked")
+	}
+	signed := 0
+	for _, address := range wallet.addresses {
+		n, err := partial.Sign(wallet.keys[address])
+		signed += n
+		if err != nil {
+			return signed, err
+		}
+	}
+	return signed, nil
+}
+
+func (wallet *Wallet) removeKey(address util.Address) {
+	delete(wallet.keys, address)
+	delete(wallet.publics, address)
+	wallet.addresses = wallet.addresses[:len(wallet.addresses)-1]
+}
@@ -0,0 +1,226 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"../config"
+	"../core"
+	"../util"
+)
+
+//WatchedOutput an unspent output paid to an address of a wallet
+type WatchedOutput struct {
+	TxID        [config.HashSize]byte
+	OutputIndex uint32
+	Address     util.Address
+	Value       uint64
+	Asset       core.AssetID
+	Height      uint64 /* index of the block confirming the output */
+}
+
+type outpoint struct {
+	txID  [config.HashSize]byte
+	index uint32
+}
+
+//Watcher follows the outputs and the history of the addresses of a wallet block by block, from a start height.
+//It only needs the addresses, so it works the same for watch-only addresses and without unlocking the wallet.
+type Watcher struct {
+	addresses map[util.Address]bool
+	start     uint64
+	hashes    [][config.HashSize]byte /* hashes of the scanned blocks from start */
+	outputs   map[outpoint]WatchedOutput
+	history   map[util.Address][]core.HistoryEntry
+}
+
+//ImportAddress Watch an address of the network without its key, e.g. to audit it
+func (wallet *Wallet) ImportAddress(address util.Address) error {
+	if address.Version() != wallet.params.PubKeyHashAddrID && address.Version() != wallet.params.ScriptHashAddrID {
+		return fmt.Errorf("Address %s doesn't belong to %s", address, wallet.params.Name)
+	}
+	if wallet.isWatched(address) {
+		return nil
+	}
+	for _, owned := range wallet.addresses {
+		if owned == address {
+			return fmt.Errorf("Wallet already has the key of %s", address)
+		}
+	}
+
+	wallet.watchOnly = append(wallet.watchOnly, address)
+	if err := wallet.Save(); err != nil {
+		wallet.watchOnly = wallet.watchOnly[:len(wallet.watchOnly)-1]
+		return err
+	}
+	return nil
+}
+
+//ImportPublicKey Watch the address of a public key without its private key
+func (wallet *Wallet) ImportPublicKey(pub util.PublicKey) (util.Address, error) {
+	if _, err := util.ParsePublicKey(pub.Bytes()); err != nil {
+		return util.Address{}, err
+	}
+	address := util.NewAddress(wallet.params.PubKeyHashAddrID, pub)
+	if _, exist := wallet.publics[address]; exist {
+		return address, nil
+	}
+
+	/* An address watched before gets its public key saved */
+	wallet.publics[address] = pub
+	var err error
+	if wallet.isWatched(address) {
+		err = wallet.Save()
+	} else {
+		err = wallet.ImportAddress(address)
+	}
+	if err != nil {
+		delete(wallet.publics, address)
+		return util.Address{}, err
+	}
+	return address, nil
+}
+
+//WatchOnlyAddresses Get the addresses watched without their keys, in the order they were imported
+func (wallet *Wallet) WatchOnlyAddresses() []util.Address {
+	return append([]util.Address{}, wallet.watchOnly...)
+}
+
+func (wallet *Wallet) isWatched(address util.Address) bool {
+	for _, watched := range wallet.watchOnly {
+		if watched == address {
+			return true
+		}
+	}
+	return false
+}
+
+//Watch Scan the chain from the block at height start for the outputs of all the addresses of the wallet,
+//its own and the watch-only ones. Outputs received before start are unknown to the watcher.
+//Addresses added to the wallet later need another Watch.
+func (wallet *Wallet) Watch(chain *core.Blockchain, start uint64) (*Watcher, error) {
+	watcher := &Watcher{addresses: make(map[util.Address]bool), start: start}
+	for _, address := range wallet.addresses {
+		watcher.addresses[address] = true
+	}
+	for _, address := range wallet.watchOnly {
+		watcher.addresses[address] = true
+	}
+	watcher.reset()
+	return watcher, watcher.Sync(chain)
+}
+
+//Sync Scan the blocks added to the chain since the last scan. If the chain doesn't contain the scanned blocks anymore
+//(a reorganization), the outputs and the history are rebuilt from the start height.
+func (watcher *Watcher) Sync(chain *core.Blockchain) error {
+	height := chain.GetLatestBlock().GetBlockIdx() + 1
+	if watcher.start > height {
+		return fmt.Errorf("Chain has no block at height %d", watcher.start)
+	}
+
+	if n := uint64(len(watcher.hashes)); n > 0 {
+		last := chain.GetBlockAt(watcher.start + n - 1)
+		if last == nil || last.GetBlockHash() != watcher.hashes[n-1] {
+			watcher.reset()
+		}
+	}
+
+	for idx := watcher.start + uint64(len(watcher.hashes)); idx < height; idx++ {
+		block := chain.GetBlockAt(idx)
+		if block == nil {
+			return errors.New("Chain changed during the scan")
+		}
+		for i := range block.Transactions {
+			watcher.scanTransaction(&block.Transactions[i], block)
+		}
+		watcher.hashes = append(watcher.hashes, block.GetBlockHash())
+	}
+	return nil
+}
+
+//Height Get the height of the next block to scan
+func (watcher *Watcher) Height() uint64 {
+	return watcher.start + uint64(len(watcher.hashes))
+}
+
+//Outputs Get the unspent outputs of the watched addresses in the order they were confirmed
+func (watcher *Watcher) Outputs() []WatchedOutput {
+	var outputs []WatchedOutput
+	for _, output := range watcher.outputs {
+		outputs = append(outputs, output)
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		if outputs[i].Height != outputs[j].Height {
+			return outputs[i].Height < outputs[j].Height
+		}
+		if outputs[i].TxID != outputs[j].TxID {
+			return string(outputs[i].TxID[:]) < string(outputs[j].TxID[:])
+		}
+		return outputs[i].OutputIndex < outputs[j].OutputIndex
+	})
+	return outputs
+}
+
+//BalanceOf Get the balance of native coins of a watched address
+func (watcher *Watcher) BalanceOf(address util.Address) uint64 {
+	var balance uint64
+	for _, output := range watcher.outputs {
+		if output.Address == address && output.Asset.IsNative() {
+			balance += output.Value
+		}
+	}
+	return balance
+}
+
+//History Get the credits and debits of a watched address in the order of the chain
+func (watcher *Watcher) History(address util.Address) []core.HistoryEntry {
+	history := append([]core.HistoryEntry{}, watcher.history[address]...)
+	for i := range history {
+		history[i].Confirmations = watcher.Height() - history[i].Height
+	}
+	return history
+}
+
+func (watcher *Watcher) reset() {
+	watcher.hashes = nil
+	watcher.outputs = make(map[outpoint]WatchedOutput)
+	watcher.history = make(map[util.Address][]core.HistoryEntry)
+}
+
+func (watcher *Watcher) scanTransaction(tran *core.Transaction, block *core.Block) {
+	txID := tran.TxID()
+	entry := core.HistoryEntry{TxID: txID, Height: block.GetBlockIdx(), TimeStampMs: block.GetTimeStampMs()}
+
+	for _, input := range tran.Inputs {
+		spent := outpoint{txID: input.PrevtxMap, index: input.OutputIndex}
+		output, watched := watcher.outputs[spent]
+		if !watched {
+			continue
+		}
+		delete(watcher.outputs, spent)
+		debit := entry
+		debit.Value = output.Value
+		debit.Asset = output.Asset
+		watcher.history[output.Address] = append(watcher.history[output.Address], debit)
+	}
+
+	for i, output := range tran.Outputs {
+		if !watcher.addresses[output.Address] || output.IsNullData() {
+			continue
+		}
+		watcher.outputs[outpoint{txID: txID, index: uint32(i)}] = WatchedOutput{
+			TxID:        txID,
+			OutputIndex: uint32(i),
+			Address:     output.Address,
+			Value:       output.Value,
+			Asset:       output.Asset,
+			Height:      block.GetBlockIdx(),
+		}
+		credit := entry
+		credit.Credit = true
+		credit.Value = output.Value
+		credit.Asset = output.Asset
+		watcher.history[output.Address] = append(watcher.history[output.Address], credit)
+	}
+}